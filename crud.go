@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/x5iu/defc/gen"
+)
+
+var crudType string
+
+var crudCmd = &cobra.Command{
+	Use:   "crud [FILE]",
+	Short: "Generate CRUD repositories from structs annotated with '// defc:table'",
+	Long: `The crud command reads a struct whose fields are tagged with 'db' and annotated with a comment such as:
+
+	// defc:table users pk=id
+	type User struct { ... }
+
+and generates a UserRepo schema interface together with its sqlx implementation, which has the Create (multi-row
+INSERT), Get, Update, UpdateNonZero, Delete and List methods. The primary key column is "id" by default and is left to
+the database on INSERT, specify 'autopk=false' to insert it as well, and 'type=Name' to rename the interface.
+
+The source file is read from the positional argument or $GOFILE, and the generated code is written to a file with a
+.crud.go suffix unless '--output' is specified. Features of the sqlx mode are all applicable, such as '--features
+sqlx/log'. If the file contains multiple annotated structs, specify one of them with the '--type/-T' parameter.`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var file string
+		if len(args) > 0 {
+			file = args[0]
+		} else if goFile := os.Getenv(EnvGoFile); goFile != "" {
+			file = goFile
+		} else {
+			return fmt.Errorf("unable to retrieve source file from the $GOFILE environment variable or positional arguments")
+		}
+		if ext := filepath.Ext(file); ext != ".go" {
+			return fmt.Errorf("crud command only supports .go files, got %q", ext)
+		}
+		pwd := os.Getenv(EnvPWD)
+		if pwd == "" {
+			if pwd, err = os.Getwd(); err != nil {
+				return fmt.Errorf("get current working directory: %w", err)
+			}
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(pwd, file)
+		}
+		doc, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("os.ReadFile(%q): %w", file, err)
+		}
+		if mode != "" && mode != gen.ModeSqlx.String() {
+			return fmt.Errorf("mode=%s is not supported by the crud command, only mode=%s is available", mode, gen.ModeSqlx)
+		}
+		mode = gen.ModeSqlx.String()
+		if output == "" {
+			output = strings.TrimSuffix(file, ".go") + ".crud.go"
+		}
+		if err = checkFlags(); err != nil {
+			return err
+		}
+		builder := gen.NewCliBuilder(gen.ModeSqlx).
+			WithFeats(features).
+			WithImports(imports).
+			WithFuncs(funcs).
+			WithPkg(os.Getenv(EnvGoPackage)).
+			WithPwd(pwd).
+			WithFile(file, doc).
+			WithTimeout(timeout)
+		var buffer bytes.Buffer
+		if err = builder.BuildCrud(&buffer, crudType); err != nil {
+			return err
+		}
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(pwd, output)
+		}
+		return save(output, buffer.Bytes())
+	},
+}
+
+func init() {
+	defc.AddCommand(crudCmd)
+	crudCmd.Flags().StringVarP(&crudType, "type", "T", "", "the struct annotated with '// defc:table'")
+}
@@ -6,10 +6,12 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"io"
 	"net/http"
 	"sort"
 	"text/template"
+	"time"
 
 	_ "embed"
 )
@@ -35,6 +37,7 @@ const (
 	FeatureApiGzip         = "api/gzip"
 	FeatureApiRetry        = "api/retry"
 	FeatureApiGetBody      = "api/get-body"
+	FeatureApiConditional  = "api/conditional"
 )
 
 func (builder *CliBuilder) buildApi(w io.Writer) error {
@@ -51,10 +54,12 @@ type apiContext struct {
 	Ident     string
 	Generics  map[string]ast.Expr
 	Methods   []*Method
+	Embeds    []ast.Expr
 	Features  []string
 	Imports   []string
 	Funcs     []string
 	Doc       Doc
+	Timeout   time.Duration
 }
 
 func (ctx *apiContext) Build(w io.Writer) error {
@@ -99,6 +104,35 @@ func (ctx *apiContext) Build(w io.Writer) error {
 			}
 		}
 
+		if !isResponse(method.Ident) && !isInner(method.Ident) {
+			timeout, err := method.Timeout()
+			if err != nil {
+				return err
+			}
+			attemptTimeout, err := method.AttemptTimeout()
+			if err != nil {
+				return err
+			}
+			if (timeout > 0 || attemptTimeout > 0) && in(ctx.Features, FeatureApiNoRt) {
+				return fmt.Errorf("method %s: TIMEOUT and ATTEMPT_TIMEOUT options require api/nort feature to be disabled",
+					quote(method.Ident))
+			}
+			backoff, _, err := method.Backoff()
+			if err != nil {
+				return err
+			}
+			if backoff > 0 {
+				if !in(ctx.Features, FeatureApiRetry) {
+					return fmt.Errorf("method %s: BACKOFF option requires api/retry feature to be enabled",
+						quote(method.Ident))
+				}
+				if in(ctx.Features, FeatureApiNoRt) {
+					return fmt.Errorf("method %s: BACKOFF option requires api/nort feature to be disabled",
+						quote(method.Ident))
+				}
+			}
+		}
+
 		// [2023-06-11] we limit 2 returned values on v1.0.0, now it is time to cancel this limitation
 		/*
 			if len(method.Out) > 2 {
@@ -116,6 +150,10 @@ func (ctx *apiContext) Build(w io.Writer) error {
 		return fmt.Errorf("api/cache, api/log, api/logx and api/client features require an `Options` method")
 	}
 
+	if !declHasInner && in(ctx.Features, FeatureApiConditional) {
+		return fmt.Errorf("api/conditional feature requires an `Options` method")
+	}
+
 	// When using the api/future feature without enabling the api/error feature, it may cause connections
 	// to not be closed properly, potentially leading to memory leak risks. To prevent this from happening,
 	// when the api/future feature is enabled, the api/error feature must also be enforced.
@@ -133,6 +171,26 @@ func (ctx *apiContext) Build(w io.Writer) error {
 		return fmt.Errorf("api/gzip feature requires api/nort feature to be disabled")
 	}
 
+	if in(ctx.Features, FeatureApiConditional) && in(ctx.Features, FeatureApiNoRt) {
+		return fmt.Errorf("api/conditional feature requires api/nort feature to be disabled")
+	}
+
+	if ctx.Timeout > 0 && in(ctx.Features, FeatureApiNoRt) {
+		return fmt.Errorf("--timeout flag requires api/nort feature to be disabled")
+	}
+
+	// The api/page feature defines its own template functions, which cannot be overridden by additional funcs
+	// since both are keys of the same FuncMap literal.
+	if in(ctx.Features, FeatureApiPage) {
+		additionalFuncs := ctx.AdditionalFuncs()
+		for _, name := range []string{"page", "next", "cursor"} {
+			if _, exists := additionalFuncs[name]; exists {
+				return fmt.Errorf("api/page feature defines the %s template function, "+
+					"which conflicts with the additional func of the same name", quote(name))
+			}
+		}
+	}
+
 	if err := ctx.genApiCode(w); err != nil {
 		return fmt.Errorf("genApiCode: %w", err)
 	}
@@ -214,6 +272,22 @@ func (ctx *apiContext) InnerType() ast.Node {
 	return nil
 }
 
+// EmbedIdent returns the name of the field of an embedded schema, e.g. "Auth" for `auth.Auth[T]`.
+func (ctx *apiContext) EmbedIdent(embed ast.Expr) string {
+	return ctx.Doc.Repr(deselect(unindex(embed)))
+}
+
+// EmbedConstructor returns the constructor generated for an embedded schema, e.g. "auth.NewAuth[T]" for
+// `auth.Auth[T]`, which is called with the Options of the embedding schema so that they are shared.
+func (ctx *apiContext) EmbedConstructor(embed ast.Expr) string {
+	var (
+		base  = unindex(embed)
+		ident = ctx.Doc.Repr(deselect(base))
+		repr  = ctx.Doc.Repr(base)
+	)
+	return repr[:len(repr)-len(ident)] + "New" + ident + ctx.Doc.Repr(embed)[len(repr):]
+}
+
 func (ctx *apiContext) MethodResponse() string {
 	for _, method := range ctx.Methods {
 		if isResponse(method.Ident) {
@@ -245,6 +319,10 @@ func (ctx *apiContext) MergedImports() (imports []string) {
 		imports = append(imports, quote("context"))
 	}
 
+	if (ctx.hasTimeout() || ctx.RetryWithRuntime()) && !in(imports, quote("time")) {
+		imports = append(imports, quote("time"))
+	}
+
 	if ctx.HasFeature(FeatureApiNoRt) {
 		imports = append(imports,
 			quote("bytes"),
@@ -257,7 +335,8 @@ func (ctx *apiContext) MergedImports() (imports []string) {
 	if ctx.HasHeader() {
 		imports = append(imports, quote("bufio"))
 		imports = append(imports, quote("net/textproto"))
-		if ctx.HasBody() && ctx.HasFeature(FeatureApiLogx) {
+		if ctx.HasBody() &&
+			(ctx.HasFeature(FeatureApiLogx) || ctx.HasFeature(FeatureApiGetBody) || ctx.HasFeature(FeatureApiRetry)) {
 			imports = append(imports, quote("bytes"))
 		}
 	}
@@ -275,6 +354,95 @@ func (ctx *apiContext) MergedImports() (imports []string) {
 	return imports
 }
 
+// apiTimeout holds the Go expressions of the timeouts of an api method.
+type apiTimeout struct {
+	// Timeout covers all attempts of a call, it is empty if the method has no timeout.
+	Timeout string
+	// Options reports whether the `Timeout() time.Duration` method of the Options type, if implemented,
+	// overrides Timeout, which is the case unless the method has the `TIMEOUT=` option.
+	Options bool
+	// AttemptTimeout covers each attempt of a call, it is "0" if the method has no attempt timeout.
+	AttemptTimeout string
+}
+
+// MethodTimeout returns the timeouts of method, from its `TIMEOUT=` and `ATTEMPT_TIMEOUT=` options, the --timeout
+// flag and the Options type, or nil if the method can not have any timeout.
+func (ctx *apiContext) MethodTimeout(method *Method) *apiTimeout {
+	if ctx.HasFeature(FeatureApiNoRt) {
+		return nil
+	}
+	var (
+		timeout, _        = method.Timeout()
+		attemptTimeout, _ = method.AttemptTimeout()
+		methodTimeout     = &apiTimeout{AttemptTimeout: "0"}
+	)
+	if timeout == 0 {
+		timeout = ctx.Timeout
+		methodTimeout.Options = ctx.HasInner()
+	}
+	if timeout > 0 {
+		methodTimeout.Timeout = durationExpr(timeout)
+	}
+	if attemptTimeout > 0 {
+		methodTimeout.AttemptTimeout = durationExpr(attemptTimeout)
+	}
+	if methodTimeout.Timeout == "" && !methodTimeout.Options && attemptTimeout == 0 {
+		return nil
+	}
+	return methodTimeout
+}
+
+// RetryWithRuntime reports whether failed attempts are retried by __rt.Retry, which waits for their backoff and
+// Retry-After, and lets the Options type decide whether they are retryable.
+func (ctx *apiContext) RetryWithRuntime() bool {
+	return ctx.HasFeature(FeatureApiRetry) && !ctx.HasFeature(FeatureApiNoRt)
+}
+
+// RetryCondition returns the Go expression deciding whether the failed attempt of method, whose response (if any) and
+// error are held by httpResponse<Ident> and err<Ident>, is retried.
+func (ctx *apiContext) RetryCondition(method *Method) string {
+	cond := "__retryCount < __maxRetry"
+	if !ctx.RetryWithRuntime() {
+		if method.HasContext() {
+			cond += " && ctx.Err() == nil"
+		}
+		return cond
+	}
+	retryCtx, retryOptions, retryDeadline := "nil", "nil", "time.Time{}"
+	if method.HasContext() {
+		retryCtx = "ctx"
+	}
+	if ctx.HasInner() {
+		retryOptions = fmt.Sprintf("__imp.%s()", ctx.MethodInner())
+	}
+	if ctx.MethodTimeout(method) != nil {
+		retryDeadline = "__deadline"
+	}
+	return fmt.Sprintf("%s && __rt.Retry(%s, %s, &__rt.Attempt{Caller: %s, Method: %s, Count: __retryCount, "+
+		"Response: httpResponse%s, Err: err%s}, %s, %s)",
+		cond, retryCtx, retryOptions, quote(method.Ident), quote(method.MethodHTTP()),
+		method.Ident, method.Ident, ctx.MethodBackoff(method), retryDeadline)
+}
+
+// MethodBackoff returns the Go expression of the backoff of method from its `BACKOFF=` option, or "nil" if the method
+// retries right away, unless the Options type implements `Backoff(*__rt.Attempt) time.Duration`.
+func (ctx *apiContext) MethodBackoff(method *Method) string {
+	base, max, _ := method.Backoff()
+	if base == 0 {
+		return "nil"
+	}
+	return fmt.Sprintf("__rt.ExpBackoff(%s, %s)", durationExpr(base), durationExpr(max))
+}
+
+func (ctx *apiContext) hasTimeout() bool {
+	for _, method := range ctx.Methods {
+		if !isResponse(method.Ident) && !isInner(method.Ident) && ctx.MethodTimeout(method) != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (ctx *apiContext) AdditionalFuncs() (funcMap map[string]string) {
 	funcMap = make(map[string]string, len(ctx.Funcs))
 	for _, fn := range ctx.Funcs {
@@ -337,7 +505,20 @@ inspectType:
 				"%s\n\n", concat(nodeMap(f.Decls, fmtNode), "\n"))
 	}
 
+	var (
+		methods = make([]*ast.Field, 0, len(ifaceType.Methods.List))
+		embeds  = make([]ast.Expr, 0, len(ifaceType.Methods.List))
+	)
+
 	for _, method := range ifaceType.Methods.List {
+		if _, ok := method.Type.(*ast.FuncType); ok {
+			methods = append(methods, method)
+		} else if method.Names == nil {
+			embeds = append(embeds, method.Type)
+		}
+	}
+
+	for _, method := range methods {
 		if funcType, ok := method.Type.(*ast.FuncType); ok {
 			if !checkInput(funcType) {
 				return nil, fmt.Errorf(""+
@@ -348,6 +529,12 @@ inspectType:
 		}
 	}
 
+	if len(embeds) > 0 {
+		if err = checkApiEmbeds(fset, f, typeSpec.Name.Name, methods, embeds, builder.doc); err != nil {
+			return nil, err
+		}
+	}
+
 	apiFeatures := make([]string, 0, len(builder.feats))
 	for _, feature := range builder.feats {
 		if hasPrefix(feature, "api") {
@@ -355,25 +542,18 @@ inspectType:
 		}
 	}
 
-	generics := make(map[string]ast.Expr, 16)
-	if typeSpec.TypeParams != nil {
-		for _, param := range typeSpec.TypeParams.List {
-			for _, name := range param.Names {
-				generics[name.Name] = param.Type
-			}
-		}
-	}
-
 	return &apiContext{
 		Package:   builder.pkg,
 		BuildTags: parseBuildTags(builder.doc),
 		Ident:     typeSpec.Name.Name,
-		Generics:  generics,
-		Methods:   typeMap(ifaceType.Methods.List, builder.doc.InspectMethod),
+		Generics:  inspectGenerics(typeSpec),
+		Methods:   typeMap(methods, builder.doc.InspectMethod),
+		Embeds:    embeds,
 		Features:  apiFeatures,
 		Imports:   builder.imports,
 		Funcs:     builder.funcs,
 		Doc:       builder.doc,
+		Timeout:   builder.timeout,
 	}, nil
 }
 
@@ -413,6 +593,59 @@ func importContext(methods []*Method) bool {
 	return false
 }
 
+// checkApiEmbeds checks that the constructor of each embedded schema takes the Options of the embedding schema, which
+// the generated constructor passes to it. Embedded schemas or Options types that cannot be resolved from the schema
+// file alone (declared in other files of the package) are left to the compiler.
+func checkApiEmbeds(fset *token.FileSet, f *ast.File, ident string, methods []*ast.Field, embeds []ast.Expr, doc Doc) error {
+	pkg, info := checkFile(fset, f)
+	var options types.Type
+	for _, method := range methods {
+		if funcType := method.Type.(*ast.FuncType); isInner(method.Names[0].Name) &&
+			funcType.Results != nil && len(funcType.Results.List) > 0 {
+			options = info.TypeOf(funcType.Results.List[0].Type)
+		}
+	}
+	typeString := func(typ types.Type) string {
+		return types.TypeString(typ, func(other *types.Package) string {
+			if other == pkg {
+				return ""
+			}
+			return other.Name()
+		})
+	}
+	for _, embed := range embeds {
+		var iface *types.Interface
+		if typ := info.TypeOf(embed); typ != nil {
+			iface, _ = typ.Underlying().(*types.Interface)
+		}
+		if iface == nil {
+			continue
+		}
+		var embedOptions types.Type
+		for i := 0; i < iface.NumMethods(); i++ {
+			if fn := iface.Method(i); isInner(fn.Name()) {
+				if results := fn.Type().(*types.Signature).Results(); results.Len() > 0 {
+					embedOptions = results.At(0).Type()
+				}
+			}
+		}
+		switch {
+		case options == nil && embedOptions == nil:
+		case options == nil:
+			return fmt.Errorf("embedded schema %s expects Options of type %s, but %s has no Options method",
+				quote(doc.Repr(embed)), typeString(embedOptions), quote(ident))
+		case embedOptions == nil:
+			return fmt.Errorf("embedded schema %s has no Options method, but %s passes Options of type %s to its constructor",
+				quote(doc.Repr(embed)), quote(ident), typeString(options))
+		case contains(typeString(options), "invalid type") || contains(typeString(embedOptions), "invalid type"):
+		case !types.Identical(options, embedOptions):
+			return fmt.Errorf("embedded schema %s expects Options of type %s, but %s passes Options of type %s to its constructor",
+				quote(doc.Repr(embed)), typeString(embedOptions), quote(ident), typeString(options))
+		}
+	}
+	return nil
+}
+
 func isResponse(ident string) bool {
 	ident = toUpper(ident)
 	return ident == apiMethodResponse || ident == apiMethodResponseHandler
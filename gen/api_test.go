@@ -3,10 +3,12 @@ package gen
 import (
 	"bufio"
 	"bytes"
+	"go/parser"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestBuildApi(t *testing.T) {
@@ -234,6 +236,45 @@ func TestBuildApi(t *testing.T) {
 			return
 		}
 	})
+	t.Run("success_embed", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiLog, FeatureApiNoRt, FeatureApiFuture})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("fail_embed_options", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "embedded schema \"SuccessWithOptions[*gofmt.State]\" "+
+			"expects Options of type *fmt.State, but \"FailEmbedOptions\" passes Options of type *fmt.Formatter") {
+			t.Errorf("build: expects EmbedOptions error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_embed_no_options", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "embedded schema \"SuccessWithOptions[*gofmt.Formatter]\" "+
+			"expects Options of type *fmt.Formatter, but \"FailEmbedNoOptions\" has no Options method") {
+			t.Errorf("build: expects EmbedOptions error, got => %s", err)
+			return
+		}
+	})
 	t.Run("success_with_options", func(t *testing.T) {
 		builder, ok := newBuilder(t)
 		if !ok {
@@ -245,4 +286,163 @@ func TestBuildApi(t *testing.T) {
 			return
 		}
 	})
+	t.Run("success_timeout", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiFuture, FeatureApiRetry, FeatureApiPage})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiLog}).WithTimeout(5 * time.Second)
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiNoRt})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "TIMEOUT and ATTEMPT_TIMEOUT options require api/nort feature to be disabled") {
+			t.Errorf("build: expects TimeoutNoRt error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_timeout", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "TIMEOUT option expects a positive duration, got -1s") {
+			t.Errorf("build: expects positive TIMEOUT error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_conditional", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiConditional})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiConditional, FeatureApiCache, FeatureApiGzip, FeatureApiFuture, FeatureApiRetry})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiConditional, FeatureApiNoRt})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "api/conditional feature requires api/nort feature to be disabled") {
+			t.Errorf("build: expects ConditionalNoRt error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_backoff", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiFuture, FeatureApiRetry})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiFuture, FeatureApiRetry, FeatureApiLog}).WithTimeout(5 * time.Second)
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiFuture})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "BACKOFF option requires api/retry feature to be enabled") {
+			t.Errorf("build: expects BackoffNoRetry error, got => %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureApiFuture, FeatureApiRetry, FeatureApiNoRt})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "BACKOFF option requires api/nort feature to be disabled") {
+			t.Errorf("build: expects BackoffNoRt error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_retry", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		for _, features := range [][]string{
+			{FeatureApiRetry, FeatureApiPage},
+			{FeatureApiRetry, FeatureApiPage, FeatureApiError},
+			{FeatureApiRetry, FeatureApiPage, FeatureApiFuture},
+			{FeatureApiRetry, FeatureApiPage, FeatureApiFuture, FeatureApiLogx},
+			{FeatureApiRetry, FeatureApiPage, FeatureApiNoRt},
+			{FeatureApiRetry, FeatureApiPage, FeatureApiFuture, FeatureApiNoRt},
+		} {
+			builder = builder.WithFeats(features)
+			if err := runTest(genFile, builder); err != nil {
+				t.Errorf("build %v: %s", features, err)
+				return
+			}
+		}
+	})
+	t.Run("success_next_page", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		for _, features := range [][]string{
+			{FeatureApiPage},
+			{FeatureApiPage, FeatureApiFuture, FeatureApiRetry},
+			{FeatureApiPage, FeatureApiNoRt},
+		} {
+			builder = builder.WithFeats(features)
+			if err := runTest(genFile, builder); err != nil {
+				t.Errorf("build %v: %s", features, err)
+				return
+			}
+		}
+		for _, name := range []string{"page", "next", "cursor"} {
+			builder = builder.WithFeats([]string{FeatureApiPage}).WithFuncs([]string{name + "=strings.TrimSpace"})
+			if err := runTest(genFile, builder); err == nil {
+				t.Errorf("build: expects errors for --func %s, got nil", name)
+				return
+			} else if !strings.Contains(err.Error(), "conflicts with the additional func") {
+				t.Errorf("build: expects conflicting func error, got => %s", err)
+				return
+			}
+		}
+	})
+}
+
+func TestApiEmbedConstructor(t *testing.T) {
+	for src, expect := range map[string][2]string{
+		"Auth":                 {"Auth", "NewAuth"},
+		"auth.Auth":            {"Auth", "auth.NewAuth"},
+		"Health[*Options]":     {"Health", "NewHealth[*Options]"},
+		"pkg.Page[T, *Option]": {"Page", "pkg.NewPage[T, *Option]"},
+	} {
+		expr, err := parser.ParseExpr(src)
+		if err != nil {
+			t.Errorf("parse: %s", err)
+			return
+		}
+		ctx := &apiContext{Doc: Doc(src)}
+		if ident, constructor := ctx.EmbedIdent(expr), ctx.EmbedConstructor(expr); ident != expect[0] || constructor != expect[1] {
+			t.Errorf("embed: (%q, %q) != (%q, %q)", ident, constructor, expect[0], expect[1])
+		}
+	}
 }
@@ -3,6 +3,7 @@ package gen
 import (
 	"go/ast"
 	"io"
+	"time"
 )
 
 type Mode int
@@ -12,6 +13,7 @@ const (
 	ModeApi
 	ModeSqlx
 	ModeRpc
+	ModeFake
 	ModeEnd
 )
 
@@ -23,6 +25,8 @@ func (mode Mode) String() string {
 		return "sqlx"
 	case ModeRpc:
 		return "rpc"
+	case ModeFake:
+		return "fake"
 	default:
 		return sprintf("Mode(%d)", mode)
 	}
@@ -87,6 +91,9 @@ type CliBuilder struct {
 
 	// template
 	template string
+
+	// timeout default timeout of methods without the `TIMEOUT=` option
+	timeout time.Duration
 }
 
 func (builder *CliBuilder) WithFeats(feats []string) *CliBuilder {
@@ -130,14 +137,24 @@ func (builder *CliBuilder) WithTemplate(template string) *CliBuilder {
 	return builder
 }
 
+func (builder *CliBuilder) WithTimeout(timeout time.Duration) *CliBuilder {
+	builder.timeout = timeout
+	return builder
+}
+
 func (builder *CliBuilder) Build(w io.Writer) error {
 	switch builder.mode {
 	case ModeApi:
 		return builder.buildApi(w)
 	case ModeSqlx:
+		if isSQLFile(builder.file) {
+			return builder.buildSqlFile(w)
+		}
 		return builder.buildSqlx(w)
 	case ModeRpc:
 		return builder.buildRpc(w)
+	case ModeFake:
+		return builder.buildFake(w)
 	default:
 	}
 	return nil
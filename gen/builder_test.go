@@ -3,6 +3,7 @@ package gen
 import (
 	"bytes"
 	goformat "go/format"
+	"io"
 	"os"
 	"testing"
 
@@ -10,8 +11,12 @@ import (
 )
 
 func runTest(path string, builder *CliBuilder) (err error) {
+	return runBuildTest(path, builder.Build)
+}
+
+func runBuildTest(path string, build func(w io.Writer) error) (err error) {
 	var bf bytes.Buffer
-	if err = builder.Build(&bf); err != nil {
+	if err = build(&bf); err != nil {
 		return err
 	}
 	code := bf.Bytes()
@@ -47,7 +52,8 @@ func TestMode(t *testing.T) {
 			{Mode: 1, String: "api", IsValid: true},
 			{Mode: 2, String: "sqlx", IsValid: true},
 			{Mode: 3, String: "rpc", IsValid: true},
-			{Mode: 4, String: "Mode(4)", IsValid: false},
+			{Mode: 4, String: "fake", IsValid: true},
+			{Mode: 5, String: "Mode(5)", IsValid: false},
 			{Mode: 999, String: "Mode(999)", IsValid: false},
 		}
 		for _, testcase := range testcases {
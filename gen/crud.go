@@ -0,0 +1,413 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+const (
+	crudAnnotation = "defc:table"
+
+	crudOptionPK     = "pk"
+	crudOptionType   = "type"
+	crudOptionAutoPK = "autopk"
+)
+
+var crudAnnotationRe = regexp.MustCompile(`^//\s*defc:table\s+(.*)$`)
+
+// crudFailFunc is added to the template functions of a CRUD schema, so that its queries fail with a clear error
+// instead of rendering an invalid statement, such as an INSERT without rows.
+const crudFailFunc = `crudFail=func(message string) (string, error) { return "", fmt.Errorf("%s", message) }`
+
+// CrudTable represents a struct annotated with `// defc:table name [pk=column] [type=Name] [autopk=false]`, whose
+// fields are mapped to the columns of the table by their `db` tags:
+//
+//	// defc:table users pk=id
+//	type User struct {
+//		ID    int64  `db:"id"`
+//		Name  string `db:"name"`
+//		Email string `db:"email"`
+//	}
+//
+// The primary key is the "id" column by default, and it is left to the database on INSERT unless autopk=false
+// is specified. The schema interface is named after the struct with a Repo suffix by default.
+type CrudTable struct {
+	Struct  string
+	Table   string
+	Type    string
+	PK      *CrudColumn
+	AutoPK  bool
+	Columns []*CrudColumn
+}
+
+// CrudColumn is an exported field of a CrudTable struct, fields tagged with `db:"-"` are ignored.
+type CrudColumn struct {
+	Field  string
+	Column string
+	Type   string
+}
+
+// ParseCrudTables parses the package name of a Go source file, and the structs annotated with `// defc:table`.
+func ParseCrudTables(file string, doc []byte) (string, []*CrudTable, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, doc, parser.ParseComments)
+	if err != nil {
+		return "", nil, err
+	}
+	var tables []*CrudTable
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			comments := typeSpec.Doc
+			if comments == nil && len(genDecl.Specs) == 1 {
+				comments = genDecl.Doc
+			}
+			annotation := crudTableAnnotation(comments)
+			if annotation == "" {
+				continue
+			}
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				return "", nil, fmt.Errorf("%s: `// %s` expects a struct type, got %s",
+					fset.Position(typeSpec.Pos()), crudAnnotation, quote(typeSpec.Name.Name))
+			}
+			if typeSpec.TypeParams != nil {
+				return "", nil, fmt.Errorf("%s: `// %s` does not support generic type %s",
+					fset.Position(typeSpec.Pos()), crudAnnotation, quote(typeSpec.Name.Name))
+			}
+			table, err := parseCrudTable(typeSpec.Name.Name, annotation, structType, doc)
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: %w", fset.Position(typeSpec.Pos()), err)
+			}
+			tables = append(tables, table)
+		}
+	}
+	return f.Name.Name, tables, nil
+}
+
+func crudTableAnnotation(comments *ast.CommentGroup) string {
+	if comments == nil {
+		return ""
+	}
+	for _, comment := range comments.List {
+		if matches := crudAnnotationRe.FindStringSubmatch(comment.Text); matches != nil {
+			return trimSpace(matches[1])
+		}
+	}
+	return ""
+}
+
+func parseCrudTable(ident string, annotation string, structType *ast.StructType, doc []byte) (*CrudTable, error) {
+	args := splitArgs(annotation)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("`// %s` expects a table name", crudAnnotation)
+	}
+	table := &CrudTable{
+		Struct: ident,
+		Table:  args[0],
+		Type:   ident + "Repo",
+		AutoPK: true,
+	}
+	pk := "id"
+	for _, arg := range args[1:] {
+		key, value, ok := cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid `// %s` option %s, expects key=value", crudAnnotation, quote(arg))
+		}
+		switch key {
+		case crudOptionPK:
+			pk = value
+		case crudOptionType:
+			if !token.IsIdentifier(value) {
+				return nil, fmt.Errorf("invalid type name %s", quote(value))
+			}
+			table.Type = value
+		case crudOptionAutoPK:
+			autoPK, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid `// %s` option %s: %w", crudAnnotation, quote(arg), err)
+			}
+			table.AutoPK = autoPK
+		default:
+			return nil, fmt.Errorf("unknown `// %s` option %s, available options are: %s, %s, %s",
+				crudAnnotation, quote(key), crudOptionPK, crudOptionType, crudOptionAutoPK)
+		}
+	}
+	for _, field := range structType.Fields.List {
+		// embedded structs are not flattened, and unexported fields are not mapped by sqlx either
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			column := toLower(name.Name)
+			if field.Tag != nil {
+				tag, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					return nil, err
+				}
+				if dbTag, ok := reflect.StructTag(tag).Lookup("db"); ok {
+					if dbTag, _, _ = cut(dbTag, ","); dbTag == "-" {
+						continue
+					} else if dbTag != "" {
+						column = dbTag
+					}
+				}
+			}
+			col := &CrudColumn{Field: name.Name, Column: column, Type: getRepr(field.Type, doc)}
+			if column == pk {
+				table.PK = col
+			}
+			table.Columns = append(table.Columns, col)
+		}
+	}
+	if table.PK == nil {
+		return nil, fmt.Errorf("no field of %s is mapped to the primary key column %s", quote(ident), quote(pk))
+	}
+	if len(table.Columns) < 2 {
+		return nil, fmt.Errorf("%s expects at least one column other than the primary key", quote(ident))
+	}
+	return table, nil
+}
+
+// Schema returns the Go source of the schema interface, and the line of its type declaration.
+func (table *CrudTable) Schema(pkg string, imports []string) ([]byte, int) {
+	var buf bytes.Buffer
+	buf.WriteString("package " + pkg + "\n\n")
+	buf.WriteString("import (\n\t\"context\"\n\t\"database/sql\"\n")
+	for _, imp := range imports {
+		buf.WriteString("\t" + imp + "\n")
+	}
+	buf.WriteString(")\n\n")
+	line := bytes.Count(buf.Bytes(), []byte("\n")) + 2
+	buf.WriteString(table.Decl())
+	return buf.Bytes(), line
+}
+
+// Decl returns the declaration of the schema interface together with its filter struct.
+func (table *CrudTable) Decl() string {
+	var (
+		buf    bytes.Buffer
+		pk     = table.PK
+		pkArg  = table.pkArg()
+		filter = table.Struct + "Filter"
+		cols   = table.columnList(table.Columns)
+	)
+	fmt.Fprintf(&buf, "// %s is the CRUD schema of table %s generated from %s.\n", table.Type, table.Table, table.Struct)
+	fmt.Fprintf(&buf, "type %s interface {\n", table.Type)
+	fmt.Fprintf(&buf, "\tWithTx(ctx context.Context, f func(%s) error) error\n\n", table.Type)
+
+	inserts := table.Columns
+	if table.AutoPK {
+		inserts = table.nonPK()
+	}
+	buf.WriteString("\t// Create EXEC BIND\n")
+	fmt.Fprintf(&buf, "\t// {{ if not $.rows }}{{ crudFail \"Create expects at least one row\" }}{{ end }}INSERT INTO %s (%s) VALUES {{ range $index, $row := $.rows }}{{ if $index }}, {{ end }}(", table.Table, table.columnList(inserts))
+	for i, col := range inserts {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "{{ bind $row.%s }}", col.Field)
+	}
+	buf.WriteString("){{ end }};\n")
+	fmt.Fprintf(&buf, "\tCreate(ctx context.Context, rows ...*%s) (sql.Result, error)\n\n", table.Struct)
+
+	buf.WriteString("\t// Get QUERY ONE CONST\n")
+	fmt.Fprintf(&buf, "\t// SELECT %s FROM %s WHERE %s = ?;\n", cols, table.Table, pk.Column)
+	fmt.Fprintf(&buf, "\tGet(ctx context.Context, %s %s) (*%s, error)\n\n", pkArg, pk.Type, table.Struct)
+
+	buf.WriteString("\t// Update EXEC BIND\n")
+	fmt.Fprintf(&buf, "\t// UPDATE %s SET ", table.Table)
+	for i, col := range table.nonPK() {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%s = {{ bind $.row.%s }}", col.Column, col.Field)
+	}
+	fmt.Fprintf(&buf, " WHERE %s = {{ bind $.row.%s }};\n", pk.Column, pk.Field)
+	fmt.Fprintf(&buf, "\tUpdate(ctx context.Context, row *%s) (sql.Result, error)\n\n", table.Struct)
+
+	buf.WriteString("\t// UpdateNonZero EXEC BIND\n")
+	fmt.Fprintf(&buf, "\t// UPDATE %s SET {{ $sep := \"\" }}", table.Table)
+	for _, col := range table.nonPK() {
+		cond := crudNonZero("$.row."+col.Field, col.Type)
+		if cond != "" {
+			fmt.Fprintf(&buf, "{{ if %s }}", cond)
+		}
+		fmt.Fprintf(&buf, "{{ $sep }}%s = {{ bind $.row.%s }}{{ $sep = \", \" }}", col.Column, col.Field)
+		if cond != "" {
+			buf.WriteString("{{ end }}")
+		}
+	}
+	buf.WriteString("{{ if not $sep }}{{ crudFail \"UpdateNonZero expects at least one non-zero field\" }}{{ end }}")
+	fmt.Fprintf(&buf, " WHERE %s = {{ bind $.row.%s }};\n", pk.Column, pk.Field)
+	fmt.Fprintf(&buf, "\tUpdateNonZero(ctx context.Context, row *%s) (sql.Result, error)\n\n", table.Struct)
+
+	buf.WriteString("\t// Delete EXEC CONST\n")
+	fmt.Fprintf(&buf, "\t// DELETE FROM %s WHERE %s = ?;\n", table.Table, pk.Column)
+	fmt.Fprintf(&buf, "\tDelete(ctx context.Context, %s %s) (sql.Result, error)\n\n", pkArg, pk.Type)
+
+	buf.WriteString("\t// List QUERY MANY BIND\n")
+	fmt.Fprintf(&buf, "\t// SELECT %s FROM %s{{ with $.filter }}{{ $sep := \" WHERE \" }}", cols, table.Table)
+	for _, col := range table.Columns {
+		fmt.Fprintf(&buf, "{{ if .%s }}{{ $sep }}%s = {{ bind .%s }}{{ $sep = \" AND \" }}{{ end }}", col.Field, col.Column, col.Field)
+	}
+	fmt.Fprintf(&buf, "{{ end }} ORDER BY %s;\n", pk.Column)
+	fmt.Fprintf(&buf, "\tList(ctx context.Context, filter *%s) ([]*%s, error)\n", filter, table.Struct)
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(&buf, "// %s filters the rows listed by %s.List, nil fields are ignored.\n", filter, table.Type)
+	fmt.Fprintf(&buf, "type %s struct {\n", filter)
+	for _, col := range table.Columns {
+		if hasPrefix(col.Type, "*") {
+			fmt.Fprintf(&buf, "\t%s %s\n", col.Field, col.Type)
+		} else {
+			fmt.Fprintf(&buf, "\t%s *%s\n", col.Field, col.Type)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func (table *CrudTable) nonPK() []*CrudColumn {
+	columns := make([]*CrudColumn, 0, len(table.Columns)-1)
+	for _, col := range table.Columns {
+		if col != table.PK {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+func (table *CrudTable) columnList(columns []*CrudColumn) string {
+	names := make([]string, 0, len(columns))
+	for _, col := range columns {
+		names = append(names, col.Column)
+	}
+	return concat(names, ", ")
+}
+
+// pkArg returns the name of the primary key argument, e.g. "userID" for the "user_id" column.
+func (table *CrudTable) pkArg() string {
+	var arg string
+	for i, word := range split(table.PK.Column, "_") {
+		if word == "" {
+			continue
+		}
+		if i == 0 {
+			arg += toLower(word)
+		} else if upper := toUpper(word); upper == "ID" {
+			arg += upper
+		} else {
+			arg += upper[:1] + word[1:]
+		}
+	}
+	if !token.IsIdentifier(arg) || token.IsKeyword(arg) || arg == "ctx" {
+		return "pk"
+	}
+	return arg
+}
+
+// crudNonZero returns the template condition which is true when expr is not the zero value, it returns an empty
+// string for struct types other than time.Time, which are always updated since templates cannot compare them.
+func crudNonZero(expr string, typ string) string {
+	switch {
+	case typ == "time.Time":
+		return "not " + expr + ".IsZero"
+	case hasPrefix(typ, "*"), hasPrefix(typ, "[]"), hasPrefix(typ, "map["), token.IsIdentifier(typ) && isBasicType(typ):
+		return expr
+	default:
+		return ""
+	}
+}
+
+func isBasicType(typ string) bool {
+	switch typ {
+	case "bool", "string", "byte", "rune",
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"float32", "float64", "complex64", "complex128":
+		return true
+	}
+	return false
+}
+
+// BuildCrud generates the schema interface of the struct annotated with `// defc:table` in the file, together with
+// its sqlx implementation. target is the name of the struct, which may be omitted when there is only one.
+func (builder *CliBuilder) BuildCrud(w io.Writer, target string) error {
+	pkg, tables, err := ParseCrudTables(builder.file, builder.doc)
+	if err != nil {
+		return fmt.Errorf("ParseCrudTables(%s): %w", quote(builder.file), err)
+	}
+	var table *CrudTable
+	for _, candidate := range tables {
+		if target == "" || candidate.Struct == target {
+			if table != nil {
+				return fmt.Errorf("ParseCrudTables(%s): multiple structs annotated with `// %s` found, "+
+					"specify one of them with the `--type` option", quote(builder.file), crudAnnotation)
+			}
+			table = candidate
+		}
+	}
+	if table == nil {
+		if target != "" {
+			return fmt.Errorf("ParseCrudTables(%s): no struct named %s is annotated with `// %s`",
+				quote(builder.file), quote(target), crudAnnotation)
+		}
+		return fmt.Errorf("ParseCrudTables(%s): no struct annotated with `// %s` found", quote(builder.file), crudAnnotation)
+	}
+	if builder.pkg != "" {
+		pkg = builder.pkg
+	}
+	imports, err := parseFileImports(builder.file, builder.doc)
+	if err != nil {
+		return err
+	}
+	schema, line := table.Schema(pkg, imports)
+	schemaBuilder := *builder
+	schemaBuilder.mode = ModeSqlx
+	schemaBuilder.pkg = pkg
+	schemaBuilder.imports = append(imports, builder.imports...)
+	schemaBuilder.doc = schema
+	schemaBuilder.funcs = append(append([]string(nil), builder.funcs...), crudFailFunc)
+	schemaBuilder.pos = line - 1
+	var buf bytes.Buffer
+	if err = schemaBuilder.buildSqlx(&buf); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	buf.WriteString(table.Decl())
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// parseFileImports returns the imports of a Go source file in the form accepted by parseImport, blank and dot
+// imports are left out.
+func parseFileImports(file string, doc []byte) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), file, doc, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	imports := make([]string, 0, len(f.Imports))
+	for _, imp := range f.Imports {
+		if imp.Name != nil {
+			if imp.Name.Name == "_" || imp.Name.Name == "." {
+				continue
+			}
+			imports = append(imports, imp.Name.Name+" "+imp.Path.Value)
+		} else {
+			imports = append(imports, imp.Path.Value)
+		}
+	}
+	return imports, nil
+}
@@ -0,0 +1,105 @@
+package gen
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCrudTables(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		testFile := filepath.Join("testdata", "crud", "model.go")
+		doc, err := os.ReadFile(testFile)
+		if err != nil {
+			t.Fatalf("read: %s", err)
+		}
+		pkg, tables, err := ParseCrudTables(testFile, doc)
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+		if pkg != "test" || len(tables) != 2 {
+			t.Fatalf("parse: unexpected %q, %d tables", pkg, len(tables))
+		}
+		user := tables[0]
+		if user.Struct != "User" || user.Table != "users" || user.Type != "UserRepo" || !user.AutoPK ||
+			user.PK.Field != "ID" {
+			t.Errorf("parse: unexpected %+v", user)
+		}
+		if columns := user.columnList(user.Columns); columns != "id, name, email, nickname, active, created_at" {
+			t.Errorf("parse: unexpected columns %q", columns)
+		}
+		role := tables[1]
+		expect := &CrudTable{
+			Struct: "UserRole",
+			Table:  "user_roles",
+			Type:   "UserRoleQuery",
+			PK:     &CrudColumn{Field: "UserID", Column: "user_id", Type: "int64"},
+			Columns: []*CrudColumn{
+				{Field: "UserID", Column: "user_id", Type: "int64"},
+				{Field: "Role", Column: "role", Type: "string"},
+			},
+		}
+		if !reflect.DeepEqual(role, expect) {
+			t.Errorf("parse: %+v != %+v", role, expect)
+		}
+		if arg := role.pkArg(); arg != "userID" {
+			t.Errorf("parse: %q != \"userID\"", arg)
+		}
+	})
+	t.Run("fail", func(t *testing.T) {
+		for _, testcase := range []struct{ doc, err string }{
+			{"// defc:table users\ntype User int", "expects a struct type"},
+			{"// defc:table users\ntype User[T any] struct{ ID T }", "does not support generic type"},
+			{"// defc:table users pk\ntype User struct{ ID int64; Name string }", "expects key=value"},
+			{"// defc:table users key=id\ntype User struct{ ID int64; Name string }", "unknown `// defc:table` option"},
+			{"// defc:table users autopk=no\ntype User struct{ ID int64; Name string }", "invalid `// defc:table` option"},
+			{"// defc:table users\ntype User struct{ UserID int64; Name string }", "primary key column \"id\""},
+			{"// defc:table users\ntype User struct{ ID int64 }", "at least one column"},
+		} {
+			if _, _, err := ParseCrudTables("model.go", []byte("package test\n\n"+testcase.doc)); err == nil ||
+				!strings.Contains(err.Error(), testcase.err) {
+				t.Errorf("parse: expects error %q for %q, got %v", testcase.err, testcase.doc, err)
+			}
+		}
+	})
+}
+
+func TestBuildCrud(t *testing.T) {
+	testFile := filepath.Join("testdata", "crud", "model.go")
+	genFile := filepath.Join("testdata", "crud", "model.crud.go")
+	doc, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	newBuilder := func(feats ...string) *CliBuilder {
+		return NewCliBuilder(ModeSqlx).WithFeats(feats).WithFile(testFile, doc)
+	}
+	for _, testcase := range []struct {
+		name   string
+		target string
+		feats  []string
+		err    string
+	}{
+		{name: "multiple", err: "multiple structs annotated"},
+		{name: "not_annotated", target: "NotAnnotated", err: "no struct named \"NotAnnotated\""},
+		{name: "user", target: "User"},
+		{name: "user_role", target: "UserRole", feats: []string{FeatureSqlxNoRt, FeatureSqlxLog}},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			builder := newBuilder(testcase.feats...)
+			err := runBuildTest(genFile, func(w io.Writer) error { return builder.BuildCrud(w, testcase.target) })
+			if testcase.err != "" {
+				if err == nil || !strings.Contains(err.Error(), testcase.err) {
+					t.Errorf("build: expects error %q, got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("build: %s", err)
+			}
+		})
+	}
+}
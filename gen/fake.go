@@ -0,0 +1,447 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"strconv"
+	"text/template"
+
+	_ "embed"
+)
+
+func (builder *CliBuilder) buildFake(w io.Writer) error {
+	inspectCtx, err := builder.inspectFake()
+	if err != nil {
+		return fmt.Errorf("inspectFake(%s, %d): %w", quote(join(builder.pwd, builder.file)), builder.pos, err)
+	}
+	return inspectCtx.Build(w)
+}
+
+type fakeContext struct {
+	Package    string
+	BuildTags  []string
+	Ident      string
+	Generics   map[string]ast.Expr
+	TypeParams *ast.FieldList
+	Methods    []*FakeMethod
+	Imports    []string
+	Doc        Doc
+}
+
+// FakeMethod is the flattened signature of an interface method, every parameter and result has its
+// own entry so that unnamed ones and grouped ones (`a, b int`) can be referenced by position.
+type FakeMethod struct {
+	Ident   string
+	Params  []*FakeParam
+	Results []*FakeParam
+}
+
+type FakeParam struct {
+	Ident    string
+	Type     string
+	Variadic bool
+}
+
+// Field returns the type used to record the parameter, variadic parameters are recorded as slices.
+func (param *FakeParam) Field() string {
+	if param.Variadic {
+		return "[]" + trimPrefix(param.Type, "...")
+	}
+	return param.Type
+}
+
+func (method *FakeMethod) ParamsRepr() string {
+	params := make([]string, 0, len(method.Params))
+	for _, param := range method.Params {
+		params = append(params, param.Ident+" "+param.Type)
+	}
+	return concat(params, ", ")
+}
+
+func (method *FakeMethod) ParamTypes() string {
+	types := make([]string, 0, len(method.Params))
+	for _, param := range method.Params {
+		types = append(types, param.Type)
+	}
+	return concat(types, ", ")
+}
+
+func (method *FakeMethod) FieldTypes() string {
+	types := make([]string, 0, len(method.Params))
+	for _, param := range method.Params {
+		types = append(types, param.Field())
+	}
+	return concat(types, ", ")
+}
+
+func (method *FakeMethod) ArgIdents() string {
+	idents := make([]string, 0, len(method.Params))
+	for _, param := range method.Params {
+		idents = append(idents, param.Ident)
+	}
+	return concat(idents, ", ")
+}
+
+func (method *FakeMethod) CallArgs() string {
+	args := make([]string, 0, len(method.Params))
+	for _, param := range method.Params {
+		if param.Variadic {
+			args = append(args, param.Ident+"...")
+		} else {
+			args = append(args, param.Ident)
+		}
+	}
+	return concat(args, ", ")
+}
+
+func (method *FakeMethod) ResultsRepr() string {
+	switch len(method.Results) {
+	case 0:
+		return ""
+	case 1:
+		return method.Results[0].Type
+	default:
+		return "(" + method.ResultTypes() + ")"
+	}
+}
+
+func (method *FakeMethod) ResultTypes() string {
+	types := make([]string, 0, len(method.Results))
+	for _, result := range method.Results {
+		types = append(types, result.Type)
+	}
+	return concat(types, ", ")
+}
+
+func (method *FakeMethod) ResultParams() string {
+	params := make([]string, 0, len(method.Results))
+	for _, result := range method.Results {
+		params = append(params, result.Ident+" "+result.Type)
+	}
+	return concat(params, ", ")
+}
+
+func (method *FakeMethod) ResultIdents(prefix string) string {
+	idents := make([]string, 0, len(method.Results))
+	for _, result := range method.Results {
+		idents = append(idents, prefix+result.Ident)
+	}
+	return concat(idents, ", ")
+}
+
+func (ctx *fakeContext) Build(w io.Writer) error {
+	if err := ctx.genFakeCode(w); err != nil {
+		return fmt.Errorf("genFakeCode: %w", err)
+	}
+	return nil
+}
+
+// GenericsRepr is built from the type parameter list instead of ctx.Generics, since parameters that
+// share a constraint (`[K, V any]`) also share a position and could not be ordered from the map.
+func (ctx *fakeContext) GenericsRepr(withType bool) string {
+	if ctx.TypeParams == nil || len(ctx.TypeParams.List) == 0 {
+		return ""
+	}
+	if withType {
+		return ctx.Doc.Repr(ctx.TypeParams)
+	}
+	names := make([]string, 0, len(ctx.Generics))
+	for _, param := range ctx.TypeParams.List {
+		for _, name := range param.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return "[" + concat(names, ", ") + "]"
+}
+
+func (ctx *fakeContext) MergedImports() (imports []string) {
+	imports = []string{quote("sync")}
+	for _, imp := range ctx.Imports {
+		if !in(imports, imp) {
+			imports = append(imports, imp)
+		}
+	}
+	return imports
+}
+
+func (builder *CliBuilder) inspectFake() (*fakeContext, error) {
+	fset := token.NewFileSet()
+
+	f, err := parser.ParseFile(fset, builder.file, builder.doc.Bytes(), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		genDecl   *ast.GenDecl
+		typeSpec  *ast.TypeSpec
+		ifaceType *ast.InterfaceType
+	)
+
+	line := builder.pos + 1
+inspectDecl:
+	for _, declIface := range f.Decls {
+		if surroundLine(fset, declIface, line) {
+			if decl, ok := declIface.(*ast.GenDecl); ok && decl.Tok == token.TYPE {
+				genDecl = decl
+				break inspectDecl
+			}
+		}
+	}
+
+	if genDecl == nil {
+		return nil, fmt.Errorf(
+			"no available 'Interface' type declaration (*ast.GenDecl) found, "+
+				"available *ast.GenDecl are: \n\n"+
+				"%s\n\n", concat(nodeMap(f.Decls, fmtNode), "\n"))
+	}
+
+inspectType:
+	for _, specIface := range genDecl.Specs {
+		if afterLine(fset, specIface, line) {
+			if spec, ok := specIface.(*ast.TypeSpec); ok {
+				if iface, ok := spec.Type.(*ast.InterfaceType); ok && afterLine(fset, iface, line) {
+					typeSpec = spec
+					ifaceType = iface
+					break inspectType
+				}
+			}
+		}
+	}
+
+	if ifaceType == nil {
+		return nil, fmt.Errorf(
+			"no available 'Interface' type declaration (*ast.InterfaceType) found, "+
+				"available *ast.GenDecl are: \n\n"+
+				"%s\n\n", concat(nodeMap(f.Decls, fmtNode), "\n"))
+	}
+
+	var (
+		methods = make([]*FakeMethod, 0, len(ifaceType.Methods.List))
+		embeds  = make([]ast.Expr, 0, len(ifaceType.Methods.List))
+	)
+
+	for _, method := range ifaceType.Methods.List {
+		if funcType, ok := method.Type.(*ast.FuncType); ok {
+			methods = append(methods, &FakeMethod{
+				Ident:   method.Names[0].Name,
+				Params:  inspectFakeParams(funcType.Params, "arg", builder.doc),
+				Results: inspectFakeParams(funcType.Results, "result", builder.doc),
+			})
+		} else if method.Names == nil {
+			embeds = append(embeds, method.Type)
+		}
+	}
+
+	// Imports of the schema file are copied as they are, so that aliased packages referenced by the
+	// method signatures resolve in the same way, goimports removes the ones left unused.
+	imports := make([]string, 0, len(f.Imports)+len(builder.imports))
+	if len(embeds) > 0 {
+		embedded, embeddedImports, err := inspectFakeEmbeds(fset, f, embeds, methods, builder.doc)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, embedded...)
+		imports = append(imports, embeddedImports...)
+	}
+	for _, imp := range f.Imports {
+		if imp.Name != nil {
+			if imp.Name.Name == "_" || imp.Name.Name == "." {
+				continue
+			}
+			imports = append(imports, imp.Name.Name+" "+imp.Path.Value)
+		} else {
+			imports = append(imports, imp.Path.Value)
+		}
+	}
+	for _, imp := range builder.imports {
+		imports = append(imports, parseImport(imp))
+	}
+	if err := checkFakeHelpers(methods); err != nil {
+		return nil, err
+	}
+
+	return &fakeContext{
+		Package:    builder.pkg,
+		BuildTags:  parseBuildTags(builder.doc),
+		Ident:      typeSpec.Name.Name,
+		Generics:   inspectGenerics(typeSpec),
+		TypeParams: typeSpec.TypeParams,
+		Methods:    methods,
+		Imports:    imports,
+		Doc:        builder.doc,
+	}, nil
+}
+
+// checkFakeHelpers returns an error if the name of a method collides with a field or a helper method generated for the
+// fake, such as GetCalls generated for Get, which would otherwise result in code that does not compile.
+func checkFakeHelpers(methods []*FakeMethod) error {
+	owners := make(map[string]string, len(methods)*7+2)
+	for _, method := range methods {
+		owners[method.Ident] = "method " + quote(method.Ident)
+	}
+	helpers := make([][2]string, 0, len(methods)*6+2)
+	for _, method := range methods {
+		suffixes := []string{"Stub", "CallCount", "Calls"}
+		if len(method.Params) > 0 {
+			suffixes = append(suffixes, "ArgsForCall")
+		}
+		if len(method.Results) > 0 {
+			suffixes = append(suffixes, "Returns", "ReturnsOnCall")
+		}
+		for _, suffix := range suffixes {
+			helpers = append(helpers, [2]string{method.Ident + suffix, "for method " + quote(method.Ident)})
+		}
+	}
+	helpers = append(helpers,
+		[2]string{"Invocations", "to record invocations"},
+		[2]string{"recordInvocation", "to record invocations"})
+	for _, helper := range helpers {
+		if owner, ok := owners[helper[0]]; ok {
+			return fmt.Errorf("%s collides with %s generated by the fake %s, rename the method",
+				owner, quote(helper[0]), helper[1])
+		}
+		owners[helper[0]] = helper[1]
+	}
+	return nil
+}
+
+func inspectFakeParams(fields *ast.FieldList, prefix string, doc Doc) []*FakeParam {
+	if fields == nil {
+		return nil
+	}
+	params := make([]*FakeParam, 0, len(fields.List))
+	for _, field := range fields.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			param := &FakeParam{
+				Ident: prefix + strconv.Itoa(len(params)+1),
+				Type:  doc.Repr(field.Type),
+			}
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				param.Variadic = true
+			}
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// inspectFakeEmbeds returns the methods of the embedded interfaces which are not declared by the interface itself, so
+// that the fake implements them as well. The schema file is type-checked on its own, which resolves interfaces declared
+// in the same file or imported from other packages, along with the imports their signatures require.
+func inspectFakeEmbeds(fset *token.FileSet, f *ast.File, embeds []ast.Expr, declared []*FakeMethod, doc Doc) ([]*FakeMethod, []string, error) {
+	var imports []string
+	// types declared in other files of the package are unknown here, which only matters if the methods of the
+	// embedded interfaces refer to them, as checked below
+	pkg, info := checkFile(fset, f)
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		for _, imp := range f.Imports {
+			if path, _ := strconv.Unquote(imp.Path.Value); path == other.Path() {
+				if imp.Name != nil && imp.Name.Name != "_" && imp.Name.Name != "." {
+					return imp.Name.Name
+				}
+				return other.Name()
+			}
+		}
+		if imp := quote(other.Path()); !in(imports, imp) {
+			imports = append(imports, imp)
+		}
+		return other.Name()
+	}
+	methods := make([]*FakeMethod, 0, 8)
+	seen := make(map[string]bool, len(declared))
+	for _, method := range declared {
+		seen[method.Ident] = true
+	}
+	for _, embed := range embeds {
+		var iface *types.Interface
+		if typ := info.TypeOf(embed); typ != nil {
+			iface, _ = typ.Underlying().(*types.Interface)
+		}
+		if iface == nil {
+			return nil, nil, fmt.Errorf("unable to resolve the methods of embedded interface %s, "+
+				"declare them in the interface instead", quote(doc.Repr(embed)))
+		}
+		for i := 0; i < iface.NumMethods(); i++ {
+			fn := iface.Method(i)
+			if seen[fn.Name()] {
+				continue
+			}
+			seen[fn.Name()] = true
+			if !fn.Exported() && fn.Pkg() != pkg {
+				return nil, nil, fmt.Errorf("embedded interface %s has unexported method %s of another package, "+
+					"which cannot be faked", quote(doc.Repr(embed)), quote(fn.Name()))
+			}
+			signature := fn.Type().(*types.Signature)
+			method := &FakeMethod{
+				Ident:   fn.Name(),
+				Params:  inspectFakeTuple(signature.Params(), signature.Variadic(), "arg", qualifier),
+				Results: inspectFakeTuple(signature.Results(), false, "result", qualifier),
+			}
+			for _, param := range append(method.Params, method.Results...) {
+				if contains(param.Type, "invalid type") {
+					return nil, nil, fmt.Errorf("unable to resolve the signature of method %s of embedded interface %s, "+
+						"declare it in the interface instead", quote(fn.Name()), quote(doc.Repr(embed)))
+				}
+			}
+			methods = append(methods, method)
+		}
+	}
+	return methods, imports, nil
+}
+
+func inspectFakeTuple(tuple *types.Tuple, variadic bool, prefix string, qualifier types.Qualifier) []*FakeParam {
+	params := make([]*FakeParam, 0, tuple.Len())
+	for i := 0; i < tuple.Len(); i++ {
+		param := &FakeParam{Ident: prefix + strconv.Itoa(i+1)}
+		if typ := tuple.At(i).Type(); variadic && i == tuple.Len()-1 {
+			param.Type = "..." + types.TypeString(typ.(*types.Slice).Elem(), qualifier)
+			param.Variadic = true
+		} else {
+			param.Type = types.TypeString(typ, qualifier)
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+func inspectGenerics(typeSpec *ast.TypeSpec) map[string]ast.Expr {
+	generics := make(map[string]ast.Expr, 16)
+	if typeSpec.TypeParams != nil {
+		for _, param := range typeSpec.TypeParams.List {
+			for _, name := range param.Names {
+				generics[name.Name] = param.Type
+			}
+		}
+	}
+	return generics
+}
+
+//go:embed template/fake.tmpl
+var fakeTemplate string
+
+func (ctx *fakeContext) genFakeCode(w io.Writer) error {
+	tmpl, err := template.
+		New("defc(fake)").
+		Funcs(template.FuncMap{
+			"getRepr": func(node ast.Node) string { return ctx.Doc.Repr(node) },
+		}).
+		Parse(fakeTemplate)
+
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, ctx)
+}
@@ -0,0 +1,182 @@
+package gen
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildFake(t *testing.T) {
+	const (
+		testPk = "test"
+		testGo = testPk + ".go"
+	)
+	var (
+		testDir  = filepath.Join("testdata", "fake")
+		testFile = testGo
+		genFile  = testPk + "." + strings.ReplaceAll(t.Name(), "/", "_") + ".go"
+	)
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Errorf("getwd: %s", err)
+		return
+	}
+	defer func() {
+		if err = os.Chdir(pwd); err != nil {
+			t.Errorf("chdir: %s", err)
+			return
+		}
+	}()
+	if err = os.Chdir(testDir); err != nil {
+		t.Errorf("chdir: %s", err)
+		return
+	}
+	newBuilder := func(t *testing.T) (*CliBuilder, bool) {
+		doc, err := os.ReadFile(testFile)
+		if err != nil {
+			t.Errorf("build: error reading %s file => %s", testGo, err)
+			return nil, false
+		}
+		var pos int
+		lineScanner := bufio.NewScanner(bytes.NewReader(doc))
+		for i := 1; lineScanner.Scan(); i++ {
+			text := lineScanner.Text()
+			if strings.HasPrefix(text, "//go:generate") &&
+				strings.HasSuffix(text, t.Name()) {
+				pos = i
+				break
+			}
+		}
+		if err = lineScanner.Err(); err != nil {
+			t.Errorf("build: error scanning %s lines => %s", testGo, err)
+			return nil, false
+		}
+		if pos == 0 {
+			t.Errorf("build: unable to get pos in %s", testGo)
+			return nil, false
+		}
+		testDirAbs, err := os.Getwd()
+		if err != nil {
+			t.Errorf("getwd: %s", err)
+			return nil, false
+		}
+		return NewCliBuilder(ModeFake).
+			WithPkg(testPk).
+			WithPwd(testDirAbs).
+			WithFile(testGo, doc).
+			WithPos(pos), true
+	}
+	t.Run("success_sqlx", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("success_api", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("success_generic_shared_constraint", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("success_rpc", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("fail_unresolved_embed", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			"unable to resolve the methods of embedded interface \"UndeclaredInterface\"") {
+			t.Errorf("build: expects UnresolvedEmbed error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_helper_collision", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			"method \"GetCalls\" collides with \"GetCalls\" generated by the fake for method \"Get\"") {
+			t.Errorf("build: expects HelperCollision error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_invocations_collision", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			"method \"Invocations\" collides with \"Invocations\" generated by the fake to record invocations") {
+			t.Errorf("build: expects InvocationsCollision error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_no_type_decl", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			"no available 'Interface' type declaration (*ast.GenDecl) found, ") {
+			t.Errorf("build: expects NoTypeDecl error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_no_iface_type", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			"no available 'Interface' type declaration (*ast.InterfaceType) found, ") {
+			t.Errorf("build: expects NoIfaceType error, got => %s", err)
+			return
+		}
+	})
+}
@@ -3,3 +3,7 @@ module api
 go 1.19
 
 replace github.com/x5iu/defc => ../../..
+
+require github.com/x5iu/defc v0.0.0-00010101000000-000000000000
+
+require github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
@@ -11,8 +11,11 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"reflect"
 	"strings"
 	"time"
+
+	defc "github.com/x5iu/defc/runtime"
 )
 
 var client Client
@@ -70,6 +73,28 @@ func main() {
 	if user.ID != 4 || user.Name != "defc_test_0004" {
 		log.Fatalf("unexpected user with MakeUpdateUserRequest: User(id=%d, name=%q)\n", user.ID, user.Name)
 	}
+	users, err := client.ListUsers(ctx)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if names := userNames(users); !reflect.DeepEqual(names, []string{"defc_test_0001", "defc_test_0002", "defc_test_0003"}) {
+		log.Fatalf("unexpected users paged by cursor: %q\n", names)
+	}
+	users, err = client.ListUsersByLink(ctx)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if names := userNames(users); !reflect.DeepEqual(names, []string{"defc_test_0001", "defc_test_0002"}) {
+		log.Fatalf("unexpected users paged by Link header: %q\n", names)
+	}
+}
+
+func userNames(users []*User) []string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	return names
 }
 
 type Transport struct {
@@ -128,6 +153,38 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 				Body:       io.NopCloser(bytes.NewBufferString(`{"code":200,"message":"","data":{"id":4,"name":"defc_test_0004"}}`)),
 			}, nil
 		}
+	case "/v1/users":
+		// pages are chained by the "next" cursor of each response
+		switch cursor := req.URL.Query().Get("cursor"); cursor {
+		case "":
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"code":200,"message":"","data":[{"id":1,"name":"defc_test_0001"}],"next":"c1"}`)),
+			}, nil
+		case "c1":
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"code":200,"message":"","data":[{"id":2,"name":"defc_test_0002"},{"id":3,"name":"defc_test_0003"}]}`)),
+			}, nil
+		default:
+			panic(fmt.Sprintf("Invalid cursor: %q", cursor))
+		}
+	case "/v1/links", "/v1/links/2":
+		// pages are chained by the relative "next" link of each response
+		header := make(http.Header)
+		data := `[{"id":2,"name":"defc_test_0002"}]`
+		if path == "/v1/links" {
+			header.Set("Link", `</v1/links/2?token=defc>; rel="next"`)
+			data = `[{"id":1,"name":"defc_test_0001"}]`
+		} else if token := req.URL.Query().Get("token"); token != "defc" {
+			panic(fmt.Sprintf("Invalid token: %q", token))
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"code":200,"message":"","data":` + data + `}`)),
+			Request:    req,
+		}, nil
 	case "/v1/users/":
 		switch method {
 		case "POST":
@@ -197,7 +254,7 @@ func (TestOptions) Log(
 	fmt.Printf("=== %s %s\nelapse: %s\n", method, url, elapse)
 }
 
-//go:generate defc generate -T Client -o client.gen.go --features api/future,api/ignore-status,api/client,api/log,api/retry --function encodejson
+//go:generate defc generate -T Client -o client.gen.go --features api/future,api/ignore-status,api/client,api/log,api/retry,api/page --function encodejson
 type Client interface {
 	Options() *TestOptions
 	ResponseHandler() *TestResponseHandler
@@ -221,6 +278,12 @@ type Client interface {
 	//
 	// {{ encodejson .req }}
 	MakeUpdateUserRequest(ctx context.Context, req *User) (*User, error)
+
+	// ListUsers GET MANY https://localhost:443/v1/users?cursor={{ cursor }}
+	ListUsers(ctx context.Context) ([]*User, error)
+
+	// ListUsersByLink GET MANY https://localhost:443/v1/links
+	ListUsersByLink(ctx context.Context) ([]*User, error)
 }
 
 type User struct {
@@ -233,13 +296,16 @@ type TestResponseHandler struct {
 	Code    int
 	Message string
 	Data    json.RawMessage
+	Next    string
 
 	currentMethodName string
+	nextLink          string
 }
 
 func (r *TestResponseHandler) FromResponse(name string, resp *http.Response) error {
 	defer resp.Body.Close()
 	r.currentMethodName = name
+	r.nextLink, _ = defc.NextLink(resp)
 	return json.NewDecoder(resp.Body).Decode(r)
 }
 
@@ -266,6 +332,16 @@ func (r *TestResponseHandler) Break() bool {
 	return false
 }
 
+func (r *TestResponseHandler) NextPage() (map[string]any, bool) {
+	switch {
+	case r.Next != "":
+		return map[string]any{"cursor": r.Next}, true
+	case r.nextLink != "":
+		return map[string]any{"url": r.nextLink}, true
+	}
+	return nil, false
+}
+
 type ResetReader struct {
 	rd *bytes.Reader
 }
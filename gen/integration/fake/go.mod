@@ -0,0 +1,3 @@
+module github.com/x5iu/defc/gen/integration/fake
+
+go 1.19
@@ -0,0 +1,90 @@
+//go:build test
+// +build test
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+)
+
+func init() {
+	log.SetFlags(log.Lshortfile | log.Lmsgprefix)
+	log.SetPrefix("[defc] ")
+}
+
+func main() {
+	ctx := context.Background()
+	store := &FakeStore{}
+	var _ Store = store
+
+	// Returns applies to every call, unless ReturnsOnCall is set for the call
+	store.GetReturns("defc", true)
+	store.GetReturnsOnCall(1, "", false)
+	for i, expect := range []struct {
+		value string
+		ok    bool
+	}{{"defc", true}, {"", false}, {"defc", true}} {
+		if value, ok := store.Get(ctx, "key"); value != expect.value || ok != expect.ok {
+			log.Fatalf("unexpected values of call %d: (%q, %v)\n", i, value, ok)
+		}
+	}
+	if n := store.GetCallCount(); n != 3 {
+		log.Fatalf("unexpected Get call count: %d\n", n)
+	}
+	if argCtx, key := store.GetArgsForCall(2); argCtx != ctx || key != "key" {
+		log.Fatalf("unexpected Get arguments: (%v, %q)\n", argCtx, key)
+	}
+
+	// Stubs take precedence over Returns, and variadic arguments are recorded as slices
+	errPut := errors.New("put")
+	store.PutReturns(nil)
+	store.PutCalls(func(key string, values ...string) error {
+		if len(values) == 0 {
+			return errPut
+		}
+		return nil
+	})
+	if err := store.Put("key", "a", "b"); err != nil {
+		log.Fatalln(err)
+	}
+	if err := store.Put("key"); err != errPut {
+		log.Fatalf("unexpected Put error: %v\n", err)
+	}
+	if key, values := store.PutArgsForCall(0); key != "key" || !reflect.DeepEqual(values, []string{"a", "b"}) {
+		log.Fatalf("unexpected Put arguments: (%q, %q)\n", key, values)
+	}
+
+	// methods of embedded interfaces are faked as well
+	store.ReadReturns(0, io.EOF)
+	buf := make([]byte, 8)
+	if n, err := store.Read(buf); n != 0 || err != io.EOF {
+		log.Fatalf("unexpected Read values: (%d, %v)\n", n, err)
+	}
+	if p := store.ReadArgsForCall(0); len(p) != len(buf) {
+		log.Fatalf("unexpected Read arguments: %v\n", p)
+	}
+	if err := store.Close(); err != nil {
+		log.Fatalf("unexpected Close error: %v\n", err)
+	}
+	if invocations := store.Invocations(); !reflect.DeepEqual(invocations,
+		[]string{"Get", "Get", "Get", "Put", "Put", "Read", "Close"}) {
+		log.Fatalf("unexpected invocations: %q\n", invocations)
+	}
+}
+
+type Closer interface {
+	Close() error
+}
+
+//go:generate defc generate --mode fake -T Store -o store.gen.go
+type Store interface {
+	io.Reader
+	Closer
+
+	Get(ctx context.Context, key string) (string, bool)
+	Put(key string, values ...string) error
+}
@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	goimport "golang.org/x/tools/imports"
+
+	"github.com/x5iu/defc/gen"
+)
+
+func TestFake(t *testing.T) {
+	var (
+		testPk      = "main"
+		testDir     = "fake"
+		testFile    = "main.go"
+		testGenFile = "store.gen.go"
+	)
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Errorf("getwd: %s", err)
+		return
+	}
+	defer func() {
+		if err = os.Chdir(pwd); err != nil {
+			t.Errorf("chdir: %s", err)
+			return
+		}
+	}()
+	if err = os.Chdir(testDir); err != nil {
+		t.Errorf("chdir: %s", err)
+		return
+	}
+	defer os.Remove(testGenFile)
+	doc, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Errorf("read %s: %s", testFile, err)
+		return
+	}
+	var pos int
+	lineScanner := bufio.NewScanner(bytes.NewReader(doc))
+	for i := 1; lineScanner.Scan(); i++ {
+		if strings.HasPrefix(lineScanner.Text(), "//go:generate") {
+			pos = i
+			break
+		}
+	}
+	if err = lineScanner.Err(); err != nil {
+		t.Errorf("scan %s: %s", testFile, err)
+		return
+	}
+	generator := gen.NewCliBuilder(gen.ModeFake).
+		WithPkg(testPk).
+		WithPwd(pwd).
+		WithFile(testFile, doc).
+		WithPos(pos)
+	var buf bytes.Buffer
+	if err = generator.Build(&buf); err != nil {
+		t.Errorf("build: %s", err)
+		return
+	}
+	code, err := goimport.Process(testGenFile, buf.Bytes(), nil)
+	if err != nil {
+		t.Errorf("fix import %s: %s", testGenFile, err)
+		return
+	}
+	if err = os.WriteFile(testGenFile, code, 0644); err != nil {
+		t.Errorf("write %s: %s", testGenFile, err)
+		return
+	}
+	if !runCommand(t, "go", "run", "-tags", "test", filepath.Join(pwd, testDir)) {
+		return
+	}
+}
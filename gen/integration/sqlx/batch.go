@@ -0,0 +1,124 @@
+//go:build test
+// +build test
+
+package main
+
+import (
+	"context"
+	"log"
+	"reflect"
+
+	defc "github.com/x5iu/defc/runtime"
+)
+
+// BatchQuery is generated by TestSqlx with either sqlx/callback or sqlx/any-callback, and the BatchCallback methods
+// of its element types are declared in batch_callback.go and batch_any_callback.go respectively.
+//
+//go:generate defc generate -T BatchQuery -o batch.gen.go --features sqlx/future
+type BatchQuery interface {
+	// InitTables exec const
+	// CREATE TABLE team (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+	// CREATE TABLE member (id INTEGER PRIMARY KEY, team_id INTEGER NOT NULL);
+	// INSERT INTO team (id, name) VALUES (1, 'team_1'), (2, 'team_2'), (3, 'team_3');
+	// INSERT INTO member (id, team_id) VALUES (10, 1), (11, 2), (12, 1);
+	InitTables(ctx context.Context) error
+
+	// ListTeams query many
+	// SELECT id, name FROM team ORDER BY id;
+	ListTeams(ctx context.Context) ([]*Team, error)
+
+	// ListTeamValues query many
+	// SELECT id, name FROM team ORDER BY id;
+	ListTeamValues() ([]TeamValue, error)
+
+	// ListMembers query many bind
+	// SELECT id, team_id FROM member WHERE team_id IN ({{ bind $.teamIDs }}) ORDER BY id;
+	ListMembers(ctx context.Context, teamIDs []int64) ([]*Member, error)
+}
+
+type Team struct {
+	ID      int64  `db:"id"`
+	Name    string `db:"name"`
+	Members []*Member
+}
+
+// TeamValue is Team returned by value, so that its BatchCallback receives []TeamValue instead of []*Team.
+type TeamValue Team
+
+type Member struct {
+	ID     int64 `db:"id"`
+	TeamID int64 `db:"team_id"`
+}
+
+var (
+	teamCallbacks      int
+	teamValueCallbacks int
+)
+
+func loadMembers(ctx context.Context, query BatchQuery, teams []*Team) error {
+	members, err := query.ListMembers(ctx, defc.Keys(teams, func(team *Team) int64 { return team.ID }))
+	if err != nil {
+		return err
+	}
+	defc.Associate(teams, members,
+		func(team *Team) int64 { return team.ID },
+		func(member *Member) int64 { return member.TeamID },
+		func(team *Team, members []*Member) { team.Members = members })
+	return nil
+}
+
+func loadValueMembers(ctx context.Context, query BatchQuery, values []TeamValue) error {
+	teams := make([]*Team, len(values))
+	for i := range values {
+		teams[i] = (*Team)(&values[i])
+	}
+	return loadMembers(ctx, query, teams)
+}
+
+func runBatchTests(ctx context.Context) {
+	db := defc.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	query := NewBatchQueryFromCore(db)
+	if err := query.InitTables(ctx); err != nil {
+		log.Fatalln(err)
+	}
+	checkMembers := func(method string, teams []*Team) {
+		for i, expect := range [][]int64{{10, 12}, {11}, nil} {
+			var ids []int64
+			for _, member := range teams[i].Members {
+				ids = append(ids, member.ID)
+			}
+			if !reflect.DeepEqual(ids, expect) {
+				log.Fatalf("%s: unexpected members of team %d: %v != %v\n", method, teams[i].ID, ids, expect)
+			}
+		}
+	}
+	teams, err := query.ListTeams(ctx)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(teams) != 3 {
+		log.Fatalf("ListTeams: expects 3 teams, got %d\n", len(teams))
+	}
+	if teamCallbacks != 1 {
+		log.Fatalf("ListTeams: expects BatchCallback to be invoked once, got %d\n", teamCallbacks)
+	}
+	checkMembers("ListTeams", teams)
+	values, err := query.ListTeamValues()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(values) != 3 {
+		log.Fatalf("ListTeamValues: expects 3 teams, got %d\n", len(values))
+	}
+	if teamValueCallbacks != 1 {
+		log.Fatalf("ListTeamValues: expects BatchCallback to be invoked once, got %d\n", teamValueCallbacks)
+	}
+	teams = make([]*Team, len(values))
+	for i := range values {
+		teams[i] = (*Team)(&values[i])
+	}
+	checkMembers("ListTeamValues", teams)
+	log.Println("All batch callback tests passed!")
+}
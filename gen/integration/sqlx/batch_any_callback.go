@@ -0,0 +1,16 @@
+//go:build test && any_callback
+// +build test,any_callback
+
+package main
+
+import "context"
+
+func (*Team) BatchCallback(ctx context.Context, query any, teams []*Team) error {
+	teamCallbacks++
+	return loadMembers(ctx, query.(BatchQuery), teams)
+}
+
+func (*TeamValue) BatchCallback(ctx context.Context, query any, values []TeamValue) error {
+	teamValueCallbacks++
+	return loadValueMembers(ctx, query.(BatchQuery), values)
+}
@@ -192,6 +192,9 @@ func main() {
 		}
 	}()
 
+	runBatchTests(ctx)
+	runCrudTests(ctx)
+
 	log.Println("All tests passed!")
 }
 
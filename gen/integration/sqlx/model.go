@@ -0,0 +1,158 @@
+//go:build test
+// +build test
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	defc "github.com/x5iu/defc/runtime"
+)
+
+// Account is generated into AccountRepo by TestSqlx in the same way as `defc crud`.
+//
+// defc:table accounts
+//
+//go:generate defc crud --features sqlx/future
+type Account struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	Email     *string   `db:"email"`
+	Active    bool      `db:"active"`
+	Balance   int64     `db:"balance"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func runCrudTests(ctx context.Context) {
+	db := defc.MustOpen("sqlite3", ":memory:")
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, `CREATE TABLE accounts (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		name       TEXT      NOT NULL,
+		email      TEXT,
+		active     BOOLEAN   NOT NULL,
+		balance    INTEGER   NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		log.Fatalln(err)
+	}
+	var (
+		repo      = NewAccountRepoFromCore(db)
+		email     = "a@defc.test"
+		createdAt = time.Date(2024, 5, 7, 12, 30, 45, 0, time.UTC)
+	)
+	ids := func(accounts []*Account) []int64 {
+		list := make([]int64, 0, len(accounts))
+		for _, account := range accounts {
+			list = append(list, account.ID)
+		}
+		return list
+	}
+	if _, err := repo.Create(ctx); err == nil || !strings.Contains(err.Error(), "Create expects at least one row") {
+		log.Fatalf("Create: expects an error without rows, got %v\n", err)
+	}
+	r, err := repo.Create(ctx,
+		&Account{Name: "a", Email: &email, Active: true, Balance: 10, CreatedAt: createdAt},
+		&Account{Name: "b", Active: false, Balance: 20, CreatedAt: createdAt},
+		&Account{Name: "c", Active: true, CreatedAt: createdAt},
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if n, _ := r.RowsAffected(); n != 3 {
+		log.Fatalf("Create: %d rows affected != 3\n", n)
+	}
+	account, err := repo.Get(ctx, 1)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if expect := (&Account{ID: 1, Name: "a", Email: &email, Active: true, Balance: 10, CreatedAt: createdAt}); !reflect.DeepEqual(account, expect) {
+		log.Fatalf("Get: %+v != %+v\n", account, expect)
+	}
+
+	account.Name, account.Email, account.Balance = "a2", nil, 0
+	if _, err = repo.Update(ctx, account); err != nil {
+		log.Fatalln(err)
+	}
+	if account, err = repo.Get(ctx, 1); err != nil {
+		log.Fatalln(err)
+	}
+	if account.Name != "a2" || account.Email != nil || account.Balance != 0 || !account.Active {
+		log.Fatalf("Update: unexpected %+v\n", account)
+	}
+
+	// Zero fields are skipped by UpdateNonZero, so Active cannot be set back to false with it.
+	if _, err = repo.UpdateNonZero(ctx, &Account{ID: 1, Name: "a3", Active: false}); err != nil {
+		log.Fatalln(err)
+	}
+	if account, err = repo.Get(ctx, 1); err != nil {
+		log.Fatalln(err)
+	}
+	if account.Name != "a3" || !account.Active || !account.CreatedAt.Equal(createdAt) {
+		log.Fatalf("UpdateNonZero: unexpected %+v\n", account)
+	}
+	if _, err = repo.UpdateNonZero(ctx, &Account{ID: 1}); err == nil ||
+		!strings.Contains(err.Error(), "UpdateNonZero expects at least one non-zero field") {
+		log.Fatalf("UpdateNonZero: expects an error without non-zero fields, got %v\n", err)
+	}
+
+	all, err := repo.List(ctx, nil)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !reflect.DeepEqual(ids(all), []int64{1, 2, 3}) {
+		log.Fatalf("List: unexpected %v\n", ids(all))
+	}
+	var (
+		inactive = false
+		active   = true
+		name     = "c"
+	)
+	for _, testcase := range []struct {
+		filter *AccountFilter
+		expect []int64
+	}{
+		{filter: &AccountFilter{}, expect: []int64{1, 2, 3}},
+		{filter: &AccountFilter{Active: &inactive}, expect: []int64{2}},
+		{filter: &AccountFilter{Active: &active, Name: &name}, expect: []int64{3}},
+		{filter: &AccountFilter{Email: &email}, expect: []int64{}},
+	} {
+		accounts, err := repo.List(ctx, testcase.filter)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if !reflect.DeepEqual(ids(accounts), testcase.expect) {
+			log.Fatalf("List: %v != %v\n", ids(accounts), testcase.expect)
+		}
+	}
+
+	errRollback := errors.New("rollback")
+	if err = repo.WithTx(ctx, func(tx AccountRepo) error {
+		if _, err := tx.Delete(ctx, 2); err != nil {
+			return err
+		}
+		return errRollback
+	}); !errors.Is(err, errRollback) {
+		log.Fatalf("WithTx: expects rollback error, got %v\n", err)
+	}
+	if _, err = repo.Get(ctx, 2); err != nil {
+		log.Fatalf("WithTx: expects row 2 to be kept, got %v\n", err)
+	}
+	if r, err = repo.Delete(ctx, 2); err != nil {
+		log.Fatalln(err)
+	}
+	if n, _ := r.RowsAffected(); n != 1 {
+		log.Fatalf("Delete: %d rows affected != 1\n", n)
+	}
+	if _, err = repo.Get(ctx, 2); !errors.Is(err, sql.ErrNoRows) {
+		log.Fatalf("Delete: expects sql.ErrNoRows, got %v\n", err)
+	}
+	log.Println("All crud tests passed!")
+}
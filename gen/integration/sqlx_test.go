@@ -14,103 +14,157 @@ import (
 	"github.com/x5iu/defc/gen"
 )
 
-func TestSqlx(t *testing.T) {
-	var (
-		testPk      = "main"
-		testDir     = "sqlx"
-		testFile    = "main.go"
-		testGenFile = "executor.gen.go"
-	)
-	pwd, err := os.Getwd()
-	if err != nil {
-		t.Errorf("getwd: %s", err)
-		return
-	}
-	defer func() {
-		if err = os.Chdir(pwd); err != nil {
-			t.Errorf("chdir: %s", err)
-			return
-		}
-	}()
-	if err = os.Chdir(testDir); err != nil {
-		t.Errorf("chdir: %s", err)
-		return
-	}
-	defer os.Remove(testGenFile)
-	doc, err := os.ReadFile(testFile)
+// sqlxTarget is a schema declared in the sqlx integration program, which is generated into its own file, crud targets
+// are structs annotated with `// defc:table` whose schema is generated by BuildCrud.
+type sqlxTarget struct {
+	crud      bool
+	file      string
+	genFile   string
+	doc       []byte
+	pos       int
+	features  []string
+	template  string
+	functions []string
+}
+
+func scanSqlxTarget(file string, genFile string) (*sqlxTarget, error) {
+	doc, err := os.ReadFile(file)
 	if err != nil {
-		t.Errorf("read %s: %s", testFile, err)
-		return
+		return nil, err
 	}
 	var (
 		featReg = regexp.MustCompile(`--features(?:\s|=)([\w,/]+)`)
 		tmplReg = regexp.MustCompile(`--template(?:\s|=)([\w:]+)`)
 		funcReg = regexp.MustCompile(`--function(?:\s|=)([\w=]+)`)
 
-		pos       int
-		features  []string
-		template  string
-		functions []string
+		target = &sqlxTarget{file: file, genFile: genFile, doc: doc}
 	)
 	lineScanner := bufio.NewScanner(bytes.NewReader(doc))
 	for i := 1; lineScanner.Scan(); i++ {
 		text := lineScanner.Text()
 		if strings.HasPrefix(text, "//go:generate") {
-			pos = i
+			target.pos = i
 			featureList := featReg.FindAllStringSubmatch(text, -1)
 			for _, sublist := range featureList {
-				features = append(features, strings.Split(sublist[1], ",")...)
+				target.features = append(target.features, strings.Split(sublist[1], ",")...)
 			}
 			templateList := tmplReg.FindAllStringSubmatch(text, -1)
 			for _, sublist := range templateList {
-				template = strings.TrimPrefix(sublist[1], ":")
+				target.template = strings.TrimPrefix(sublist[1], ":")
 			}
 			functionList := funcReg.FindAllStringSubmatch(text, -1)
 			for _, sublist := range functionList {
-				functions = append(functions, sublist[1])
+				target.functions = append(target.functions, sublist[1])
 			}
 			break
 		}
 	}
 	if err = lineScanner.Err(); err != nil {
-		t.Errorf("scan %s: %s", testFile, err)
+		return nil, err
+	}
+	return target, nil
+}
+
+func TestSqlx(t *testing.T) {
+	var (
+		testPk  = "main"
+		testDir = "sqlx"
+	)
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Errorf("getwd: %s", err)
 		return
 	}
-	runTest := func(t *testing.T, feats ...string) {
+	defer func() {
+		if err = os.Chdir(pwd); err != nil {
+			t.Errorf("chdir: %s", err)
+			return
+		}
+	}()
+	if err = os.Chdir(testDir); err != nil {
+		t.Errorf("chdir: %s", err)
+		return
+	}
+	executor, err := scanSqlxTarget("main.go", "executor.gen.go")
+	if err != nil {
+		t.Errorf("scan main.go: %s", err)
+		return
+	}
+	defer os.Remove(executor.genFile)
+	batch, err := scanSqlxTarget("batch.go", "batch.gen.go")
+	if err != nil {
+		t.Errorf("scan batch.go: %s", err)
+		return
+	}
+	defer os.Remove(batch.genFile)
+	model, err := scanSqlxTarget("model.go", "model.crud.go")
+	if err != nil {
+		t.Errorf("scan model.go: %s", err)
+		return
+	}
+	model.crud = true
+	defer os.Remove(model.genFile)
+	generate := func(t *testing.T, target *sqlxTarget, feats ...string) bool {
 		generator := gen.NewCliBuilder(gen.ModeSqlx).
 			WithPkg(testPk).
 			WithPwd(pwd).
-			WithFile(testFile, doc).
-			WithPos(pos).
+			WithFile(target.file, target.doc).
+			WithPos(target.pos).
 			WithImports(nil).
-			WithFeats(append(features, feats...)).
-			WithTemplate(template).
-			WithFuncs(functions)
-		var buf bytes.Buffer
-		if err = generator.Build(&buf); err != nil {
-			t.Errorf("build: %s", err)
-			return
+			WithFeats(append(target.features[:len(target.features):len(target.features)], feats...)).
+			WithTemplate(target.template).
+			WithFuncs(target.functions)
+		var (
+			buf bytes.Buffer
+			err error
+		)
+		if target.crud {
+			err = generator.BuildCrud(&buf, "")
+		} else {
+			err = generator.Build(&buf)
 		}
-		if err = os.WriteFile(testGenFile, buf.Bytes(), 0644); err != nil {
-			t.Errorf("write %s: %s", testGenFile, err)
-			return
+		if err != nil {
+			t.Errorf("build %s: %s", target.file, err)
+			return false
 		}
-		code, err := goimport.Process(testGenFile, buf.Bytes(), nil)
+		if err := os.WriteFile(target.genFile, buf.Bytes(), 0644); err != nil {
+			t.Errorf("write %s: %s", target.genFile, err)
+			return false
+		}
+		code, err := goimport.Process(target.genFile, buf.Bytes(), nil)
 		if err != nil {
-			t.Errorf("fix import %s: %s", testGenFile, err)
-			return
+			t.Errorf("fix import %s: %s", target.genFile, err)
+			return false
 		}
-		if err = os.WriteFile(testGenFile, code, 0644); err != nil {
-			t.Errorf("write %s: %s", testGenFile, err)
-			return
+		if err = os.WriteFile(target.genFile, code, 0644); err != nil {
+			t.Errorf("write %s: %s", target.genFile, err)
+			return false
 		}
+		return true
+	}
+	run := func(t *testing.T, tags string) {
 		if !runCommand(t, "go", "mod", "tidy") {
 			return
 		}
-		if !runCommand(t, "go", "run", "-tags", "test", filepath.Join(pwd, testDir)) {
+		if !runCommand(t, "go", "run", "-tags", tags, filepath.Join(pwd, testDir)) {
 			return
 		}
 	}
-	t.Run("rt", func(t *testing.T) { runTest(t) })
-	t.Run("nort", func(t *testing.T) { runTest(t, gen.FeatureSqlxNoRt) })
+	t.Run("rt", func(t *testing.T) {
+		if generate(t, executor) && generate(t, batch, gen.FeatureSqlxCallback) && generate(t, model) {
+			run(t, "test")
+		}
+	})
+	t.Run("nort", func(t *testing.T) {
+		if generate(t, executor, gen.FeatureSqlxNoRt) &&
+			generate(t, batch, gen.FeatureSqlxNoRt, gen.FeatureSqlxCallback) &&
+			generate(t, model, gen.FeatureSqlxNoRt) {
+			run(t, "test")
+		}
+	})
+	t.Run("any_callback", func(t *testing.T) {
+		if generate(t, executor) && generate(t, batch, gen.FeatureSqlxAnyCallback) && generate(t, model) {
+			run(t, "test,any_callback")
+		}
+	})
 }
@@ -6,6 +6,7 @@ import (
 	"go/ast"
 	"net/http"
 	"regexp"
+	"time"
 )
 
 // Method represents a method declaration in an interface
@@ -160,6 +161,92 @@ func (method *Method) SqlxOptions() []string {
 	return nil
 }
 
+// SqlxDialect is a variant of the query of a method for some SQL dialects, which follows a
+// `-- dialect: name[, name...]` line in the method comment. The default variant is used for
+// all other dialects, it is either the content before the first `-- dialect:` line or the
+// section following `-- dialect: default`.
+type SqlxDialect struct {
+	Index    int
+	Dialects []string
+	Default  bool
+	Header   string
+}
+
+var dialectRe = regexp.MustCompile(`^--\s*dialect:\s*(.*?)\s*$`)
+
+// SqlxDialects should only be used with '--mode=sqlx' arg, it returns nil when there is
+// no `-- dialect:` line in the method comment.
+func (method *Method) SqlxDialects() ([]*SqlxDialect, error) {
+	var (
+		variants []*SqlxDialect
+		current  = &SqlxDialect{Default: true}
+		header   bytes.Buffer
+		sections bool
+		seen     = make(map[string]bool)
+	)
+	flush := func() error {
+		current.Header = header.String()
+		header.Reset()
+		if trimSpace(current.Header) == "" {
+			if current.Default && len(variants) == 0 && !sections {
+				// no content before the first `-- dialect:` line
+				return nil
+			}
+			return fmt.Errorf("method %s: empty query for dialect %s",
+				quote(method.Ident), quote(concat(current.Dialects, ", ")))
+		}
+		current.Index = len(variants)
+		variants = append(variants, current)
+		return nil
+	}
+	for _, line := range split(method.Header, "\n") {
+		matches := dialectRe.FindStringSubmatch(trimSpace(line))
+		if matches == nil {
+			header.WriteString(line)
+			header.WriteString("\n")
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		sections = true
+		current = new(SqlxDialect)
+		for _, dialect := range split(matches[1], ",") {
+			if dialect = trimSpace(dialect); dialect == "" {
+				continue
+			}
+			if dialect == "default" {
+				current.Default = true
+			} else {
+				current.Dialects = append(current.Dialects, dialect)
+			}
+			if seen[dialect] {
+				return nil, fmt.Errorf("method %s: duplicate dialect %s", quote(method.Ident), quote(dialect))
+			}
+			seen[dialect] = true
+		}
+		if current.Default && len(current.Dialects) > 0 {
+			return nil, fmt.Errorf("method %s: the default dialect cannot be combined with %s",
+				quote(method.Ident), quote(concat(current.Dialects, ", ")))
+		}
+		if !current.Default && len(current.Dialects) == 0 {
+			return nil, fmt.Errorf("method %s: expects `-- dialect: name[, name...]`", quote(method.Ident))
+		}
+	}
+	if !sections {
+		return nil, nil
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	for _, variant := range variants[1:] {
+		if variant.Default && variants[0].Default {
+			return nil, fmt.Errorf("method %s: duplicate dialect %s", quote(method.Ident), quote("default"))
+		}
+	}
+	return variants, nil
+}
+
 func (method *Method) HasContext() bool {
 	for ident, ty := range method.In {
 		if isContextType(ident, ty, method.Source) {
@@ -258,6 +345,127 @@ func (method *Method) ArgumentsVar() string {
 	return ""
 }
 
+// BuilderArg should only be used with '--mode=sqlx' arg, it returns the only argument other than context.Context
+// (and the cursor and page size of the PAGE option) of a method with the BUILDER option, or an empty string if there
+// is not exactly one such argument.
+func (method *Method) BuilderArg() string {
+	var builder string
+	for _, ident := range method.SortIn() {
+		if isContextType(ident, method.In[ident], method.Source) || method.IsSqlxPageArg(ident) {
+			continue
+		}
+		if builder != "" {
+			return ""
+		}
+		builder = ident
+	}
+	return builder
+}
+
+// SqlxPage should only be used with '--mode=sqlx' arg, it returns the columns of the `PAGE=keyset(col1,col2)`
+// option, or nil when the method is not paginated.
+func (method *Method) SqlxPage() ([]string, error) {
+	const (
+		prefix = "PAGE="
+		keyset = "KEYSET("
+	)
+	if args := method.MetaArgs(); len(args) >= 3 {
+		for _, opt := range args[2:] {
+			if len(opt) < len(prefix) || toUpper(opt[:len(prefix)]) != prefix {
+				continue
+			}
+			spec := opt[len(prefix):]
+			if len(spec) <= len(keyset) || toUpper(spec[:len(keyset)]) != keyset || spec[len(spec)-1] != ')' {
+				return nil, fmt.Errorf("method %s: invalid option %s, expects PAGE=keyset(col1,col2...)",
+					quote(method.Ident), opt)
+			}
+			var columns []string
+			for _, column := range split(spec[len(keyset):len(spec)-1], ",") {
+				if column = trimSpace(column); column == "" {
+					return nil, fmt.Errorf("method %s: empty column in option %s", quote(method.Ident), opt)
+				}
+				columns = append(columns, column)
+			}
+			return columns, nil
+		}
+	}
+	return nil, nil
+}
+
+// SqlxGroup should only be used with '--mode=sqlx' arg, it returns the key columns of the `GROUP=id` option, or nil
+// when rows are not grouped.
+func (method *Method) SqlxGroup() ([]string, error) {
+	const prefix = "GROUP="
+	if args := method.MetaArgs(); len(args) >= 3 {
+		for _, opt := range args[2:] {
+			if len(opt) < len(prefix) || toUpper(opt[:len(prefix)]) != prefix {
+				continue
+			}
+			var columns []string
+			for _, column := range split(opt[len(prefix):], ",") {
+				if column = trimSpace(column); column == "" {
+					return nil, fmt.Errorf("method %s: invalid option %s, expects GROUP=col1,col2...",
+						quote(method.Ident), opt)
+				}
+				columns = append(columns, column)
+			}
+			return columns, nil
+		}
+	}
+	return nil, nil
+}
+
+// SqlxInvalidate should only be used with '--mode=sqlx' arg, it returns the methods named by the
+// `INVALIDATE=GetUser,Other.ListUsers` option, or nil when the method invalidates no cached results.
+func (method *Method) SqlxInvalidate() ([]string, error) {
+	const prefix = "INVALIDATE="
+	if args := method.MetaArgs(); len(args) >= 3 {
+		for _, opt := range args[2:] {
+			if len(opt) < len(prefix) || toUpper(opt[:len(prefix)]) != prefix {
+				continue
+			}
+			var methods []string
+			for _, name := range split(opt[len(prefix):], ",") {
+				if name = trimSpace(name); name == "" {
+					return nil, fmt.Errorf("method %s: invalid option %s, expects INVALIDATE=Method1,Method2...",
+						quote(method.Ident), opt)
+				}
+				methods = append(methods, name)
+			}
+			return methods, nil
+		}
+	}
+	return nil, nil
+}
+
+// SqlxPageArgs should only be used with '--mode=sqlx' arg, it returns the cursor and page size arguments of a
+// paginated method, which are its last two arguments other than context.Context.
+func (method *Method) SqlxPageArgs() []string {
+	args := make([]string, 0, len(method.OrderedIn))
+	for _, ident := range method.SortIn() {
+		if !isContextType(ident, method.In[ident], method.Source) {
+			args = append(args, ident)
+		}
+	}
+	if len(args) < 2 {
+		return nil
+	}
+	return args[len(args)-2:]
+}
+
+// IsSqlxPageArg reports whether ident is the cursor or page size argument of a paginated method.
+func (method *Method) IsSqlxPageArg(ident string) bool {
+	if columns, _ := method.SqlxPage(); columns == nil {
+		return false
+	}
+	for _, arg := range method.SqlxPageArgs() {
+		if arg == ident {
+			return true
+		}
+	}
+	return false
+}
+
 // ConstBindResult represents the result of parsing constbind expressions
 type ConstBindResult struct {
 	SQL  string   // SQL with ${...} replaced by ?
@@ -368,6 +576,75 @@ func (method *Method) MaxRetry() string {
 	return "2"
 }
 
+// Timeout returns the duration of the `TIMEOUT=` option, or zero if the option is absent.
+func (method *Method) Timeout() (time.Duration, error) {
+	return method.durationOption("TIMEOUT=")
+}
+
+// AttemptTimeout should only be used with '--mode=api' arg, it returns the duration of the
+// `ATTEMPT_TIMEOUT=` option, or zero if the option is absent.
+func (method *Method) AttemptTimeout() (time.Duration, error) {
+	return method.durationOption("ATTEMPT_TIMEOUT=")
+}
+
+// Backoff should only be used with '--mode=api' arg, it returns the base and maximum delays of the
+// `BACKOFF=exp(100ms,2s)` option, or zeros if the option is absent.
+func (method *Method) Backoff() (base time.Duration, max time.Duration, err error) {
+	const (
+		prefix = "BACKOFF="
+		exp    = "EXP("
+	)
+	if args := method.MetaArgs(); len(args) >= 3 {
+		for _, opt := range args[2:] {
+			if len(opt) < len(prefix) || toUpper(opt[:len(prefix)]) != prefix {
+				continue
+			}
+			spec := opt[len(prefix):]
+			if len(spec) <= len(exp) || toUpper(spec[:len(exp)]) != exp || spec[len(spec)-1] != ')' {
+				return 0, 0, fmt.Errorf("method %s: invalid option %s, expects BACKOFF=exp(base,max)",
+					quote(method.Ident), opt)
+			}
+			delays := split(spec[len(exp):len(spec)-1], ",")
+			if len(delays) != 2 {
+				return 0, 0, fmt.Errorf("method %s: invalid option %s, expects BACKOFF=exp(base,max)",
+					quote(method.Ident), opt)
+			}
+			if base, err = time.ParseDuration(trimSpace(delays[0])); err == nil {
+				max, err = time.ParseDuration(trimSpace(delays[1]))
+			}
+			if err != nil {
+				return 0, 0, fmt.Errorf("method %s: invalid BACKOFF option: %w", quote(method.Ident), err)
+			}
+			if base <= 0 || max < base {
+				return 0, 0, fmt.Errorf("method %s: BACKOFF option expects 0 < base <= max, got %s",
+					quote(method.Ident), opt)
+			}
+			return base, max, nil
+		}
+	}
+	return 0, 0, nil
+}
+
+func (method *Method) durationOption(prefix string) (time.Duration, error) {
+	if args := method.MetaArgs(); len(args) >= 3 {
+		for _, arg := range args[2:] {
+			if len(arg) > len(prefix) && toUpper(arg[:len(prefix)]) == prefix {
+				duration, err := time.ParseDuration(arg[len(prefix):])
+				if err != nil {
+					return 0, fmt.Errorf("method %s: invalid %s option: %w",
+						quote(method.Ident), prefix[:len(prefix)-1], err)
+				}
+				if duration <= 0 {
+					return 0, fmt.Errorf("method %s: %s option expects a positive duration, got %s",
+						quote(method.Ident), prefix[:len(prefix)-1], arg[len(prefix):])
+				}
+				return duration, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
 // RequestOptions should only be used with '--mode=api' arg
 func (method *Method) RequestOptions() string {
 	if args := method.MetaArgs(); len(args) >= 3 {
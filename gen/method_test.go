@@ -2,7 +2,9 @@ package gen
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMethod(t *testing.T) {
@@ -178,3 +180,138 @@ func TestParseConstBindExpressions(t *testing.T) {
 		})
 	}
 }
+
+func TestSqlxDialects(t *testing.T) {
+	m := &Method{Ident: "Test", Header: "SELECT 1;"}
+	if dialects, err := m.SqlxDialects(); err != nil || dialects != nil {
+		t.Errorf("method: %v (%v) != nil", dialects, err)
+		return
+	}
+	m.Header = "SELECT 1;\n-- dialect: postgres, pgx\nSELECT 2;\n--dialect:mysql\nSELECT 3;"
+	dialects, err := m.SqlxDialects()
+	if err != nil {
+		t.Errorf("method: %s", err)
+		return
+	}
+	expects := []*SqlxDialect{
+		{Index: 0, Default: true, Header: "SELECT 1;\n"},
+		{Index: 1, Dialects: []string{"postgres", "pgx"}, Header: "SELECT 2;\n"},
+		{Index: 2, Dialects: []string{"mysql"}, Header: "SELECT 3;\n"},
+	}
+	if !reflect.DeepEqual(dialects, expects) {
+		t.Errorf("method: %+v != %+v", dialects, expects)
+		return
+	}
+	for header, expect := range map[string]string{
+		"-- dialect: mysql\n-- dialect: postgres\nSELECT 1;":         `empty query for dialect "mysql"`,
+		"-- dialect: mysql\nSELECT 1;\n-- dialect: mysql\nSELECT 2;": `duplicate dialect "mysql"`,
+		"SELECT 1;\n-- dialect: default\nSELECT 2;":                  `duplicate dialect "default"`,
+		"-- dialect: default, mysql\nSELECT 1;":                      "cannot be combined",
+		"-- dialect: ,\nSELECT 1;":                                   "expects `-- dialect: name[, name...]`",
+	} {
+		m.Header = header
+		if _, err = m.SqlxDialects(); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("method: expects error %q for %q, got %v", expect, header, err)
+		}
+	}
+}
+
+func TestSqlxPage(t *testing.T) {
+	m := &Method{Ident: "Test", Meta: "Test QUERY MANY"}
+	if columns, err := m.SqlxPage(); err != nil || columns != nil {
+		t.Errorf("method: %v (%v) != nil", columns, err)
+		return
+	}
+	m.Meta = "Test QUERY MANY PAGE=keyset(created_at desc, id desc)"
+	columns, err := m.SqlxPage()
+	if err != nil {
+		t.Errorf("method: %s", err)
+		return
+	}
+	if expects := []string{"created_at desc", "id desc"}; !reflect.DeepEqual(columns, expects) {
+		t.Errorf("method: %v != %v", columns, expects)
+		return
+	}
+	for meta, expect := range map[string]string{
+		"Test QUERY MANY PAGE=offset(10)":  "expects PAGE=keyset(col1,col2...)",
+		"Test QUERY MANY PAGE=keyset()":    "empty column",
+		"Test QUERY MANY PAGE=keyset(id,)": "empty column",
+	} {
+		m.Meta = meta
+		if _, err = m.SqlxPage(); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("method: expects error %q for %q, got %v", expect, meta, err)
+		}
+	}
+}
+
+func TestMethodTimeout(t *testing.T) {
+	m := &Method{Ident: "Test", Meta: "Test GET TIMEOUT=1m30s ATTEMPT_TIMEOUT=500ms https://localhost/path"}
+	if timeout, err := m.Timeout(); err != nil || timeout != 90*time.Second {
+		t.Errorf("method: %s (%v) != %s", timeout, err, 90*time.Second)
+		return
+	}
+	if timeout, err := m.AttemptTimeout(); err != nil || timeout != 500*time.Millisecond {
+		t.Errorf("method: %s (%v) != %s", timeout, err, 500*time.Millisecond)
+		return
+	}
+	for duration, expect := range map[time.Duration]string{
+		90 * time.Second:        "90 * time.Second",
+		time.Hour:               "time.Hour",
+		1500 * time.Microsecond: "1500 * time.Microsecond",
+		7:                       "7 * time.Nanosecond",
+	} {
+		if expr := durationExpr(duration); expr != expect {
+			t.Errorf("method: %s != %s", expr, expect)
+		}
+	}
+	m.Meta = "Test QUERY ONE TIMEOUT=soon"
+	if _, err := m.Timeout(); err == nil || !strings.Contains(err.Error(), "invalid TIMEOUT option") {
+		t.Errorf("method: expects invalid TIMEOUT error, got %v", err)
+	}
+}
+
+func TestMethodBackoff(t *testing.T) {
+	m := &Method{Ident: "Test", Meta: "Test GET RETRY=3 https://localhost/path"}
+	if base, max, err := m.Backoff(); err != nil || base != 0 || max != 0 {
+		t.Errorf("method: %s, %s (%v) != 0, 0", base, max, err)
+		return
+	}
+	m.Meta = "Test GET RETRY=3 BACKOFF=exp(100ms,2s) https://localhost/path"
+	if base, max, err := m.Backoff(); err != nil || base != 100*time.Millisecond || max != 2*time.Second {
+		t.Errorf("method: %s, %s (%v) != %s, %s", base, max, err, 100*time.Millisecond, 2*time.Second)
+		return
+	}
+	for meta, expect := range map[string]string{
+		"Test GET BACKOFF=linear(1s,2s) https://localhost/path": "expects BACKOFF=exp(base,max)",
+		"Test GET BACKOFF=exp(1s) https://localhost/path":       "expects BACKOFF=exp(base,max)",
+		"Test GET BACKOFF=exp(1s,soon) https://localhost/path":  "invalid BACKOFF option",
+		"Test GET BACKOFF=exp(2s,1s) https://localhost/path":    "expects 0 < base <= max",
+	} {
+		m.Meta = meta
+		if _, _, err := m.Backoff(); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("method: expects %q error, got %v", expect, err)
+		}
+	}
+}
+
+func TestSqlxInvalidate(t *testing.T) {
+	m := &Method{Ident: "Test", Meta: "Test EXEC"}
+	if methods, err := m.SqlxInvalidate(); err != nil || methods != nil {
+		t.Errorf("method: %v (%v) != nil", methods, err)
+		return
+	}
+	m.Meta = "Test EXEC INVALIDATE=GetUser,Other.ListUsers"
+	methods, err := m.SqlxInvalidate()
+	if err != nil {
+		t.Errorf("method: %s", err)
+		return
+	}
+	if expects := []string{"GetUser", "Other.ListUsers"}; !reflect.DeepEqual(methods, expects) {
+		t.Errorf("method: %v != %v", methods, expects)
+		return
+	}
+	m.Meta = "Test EXEC INVALIDATE=GetUser,"
+	if _, err = m.SqlxInvalidate(); err == nil || !strings.Contains(err.Error(), "expects INVALIDATE=Method1,Method2") {
+		t.Errorf("method: expects invalid INVALIDATE error, got %v", err)
+	}
+}
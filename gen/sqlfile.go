@@ -0,0 +1,326 @@
+package gen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	sqlFileExt = ".sql"
+
+	sqlFileAnnotationPackage = "package"
+	sqlFileAnnotationType    = "type"
+	sqlFileAnnotationImport  = "import"
+	sqlFileAnnotationName    = "name"
+	sqlFileAnnotationParam   = "param"
+	sqlFileAnnotationParams  = "params"
+	sqlFileAnnotationResult  = "result"
+
+	sqlFileCmdOne        = ":one"
+	sqlFileCmdMany       = ":many"
+	sqlFileCmdExec       = ":exec"
+	sqlFileCmdExecResult = ":execresult"
+
+	// sqlFileParamsIdent is the name of the parameter declared by the `-- params:` annotation,
+	// which is the name sqlc uses for its parameter structs.
+	sqlFileParamsIdent = "arg"
+)
+
+var (
+	sqlFileAnnotationRe = regexp.MustCompile(`^--\s*([a-z]+):\s*(.*)$`)
+	sqlFileIdentRe      = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// SQLFile represents a .sql file whose queries are annotated in the style of sqlc:
+//
+//	-- package: query
+//	-- type: UserQuery
+//	-- import: "github.com/example/models"
+//
+//	-- name: GetUser :one
+//	-- param: id int64
+//	-- result: *models.User
+//	SELECT * FROM users WHERE id = ?;
+//
+//	-- name: CreateUser :exec
+//	-- params: models.CreateUserParams
+//	INSERT INTO users (name, email) VALUES (:name, :email);
+//
+// The file level annotations must precede the first query, and all of them are optional.
+type SQLFile struct {
+	Package string
+	Type    string
+	Imports []string
+	Queries []*SQLQuery
+}
+
+// SQLQuery represents a query annotated with `-- name: Name :command [OPTIONS...]`.
+type SQLQuery struct {
+	Name    string
+	Command string
+	Options []string
+	Params  [][2]string
+	Result  string
+	Query   string
+	Line    int
+}
+
+// ParseSQLFile parses the annotations and queries of a .sql file.
+func ParseSQLFile(doc []byte) (*SQLFile, error) {
+	var (
+		file    = new(SQLFile)
+		query   *SQLQuery
+		body    []string
+		names   = make(map[string]bool)
+		scanner = bufio.NewScanner(bytes.NewReader(doc))
+	)
+	finish := func() error {
+		if query == nil {
+			return nil
+		}
+		query.Query = trimSpace(concat(body, "\n"))
+		if query.Query == "" {
+			return fmt.Errorf("line %d: query %s has no sql statement", query.Line, quote(query.Name))
+		}
+		body = nil
+		return nil
+	}
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := trimSpace(text)
+		matches := sqlFileAnnotationRe.FindStringSubmatch(trimmed)
+		if matches == nil {
+			if query == nil {
+				if trimmed != "" && !hasPrefix(trimmed, "--") {
+					return nil, fmt.Errorf("line %d: sql statement found before the first `-- name:` annotation", line)
+				}
+			} else if !hasPrefix(trimmed, "--") && (trimmed != "" || len(body) > 0) {
+				body = append(body, text)
+			}
+			continue
+		}
+		key, value := matches[1], trimSpace(matches[2])
+		if key == sqlFileAnnotationName {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			args := strings.Fields(value)
+			if len(args) < 2 {
+				return nil, fmt.Errorf("line %d: expects `-- name: Name :command`, got %s", line, quote(trimmed))
+			}
+			if !token.IsIdentifier(args[0]) {
+				return nil, fmt.Errorf("line %d: invalid query name %s", line, quote(args[0]))
+			}
+			if names[args[0]] {
+				return nil, fmt.Errorf("line %d: duplicate query name %s", line, quote(args[0]))
+			}
+			names[args[0]] = true
+			switch command := toLower(args[1]); command {
+			case sqlFileCmdOne, sqlFileCmdMany, sqlFileCmdExec, sqlFileCmdExecResult:
+				query = &SQLQuery{Name: args[0], Command: command, Line: line}
+			default:
+				return nil, fmt.Errorf("line %d: unsupported command %s, available commands are: %s, %s, %s, %s",
+					line, quote(args[1]), sqlFileCmdOne, sqlFileCmdMany, sqlFileCmdExec, sqlFileCmdExecResult)
+			}
+			for _, opt := range args[2:] {
+				query.Options = append(query.Options, toUpper(opt))
+			}
+			file.Queries = append(file.Queries, query)
+			continue
+		}
+		if query == nil {
+			switch key {
+			case sqlFileAnnotationPackage:
+				file.Package = value
+			case sqlFileAnnotationType:
+				file.Type = value
+			case sqlFileAnnotationImport:
+				file.Imports = append(file.Imports, value)
+			}
+			continue
+		}
+		switch key {
+		case sqlFileAnnotationParam:
+			ident, typ, ok := cut(value, " ")
+			if typ = trimSpace(typ); !ok || typ == "" || !token.IsIdentifier(ident) {
+				return nil, fmt.Errorf("line %d: expects `-- param: name type`, got %s", line, quote(trimmed))
+			}
+			query.Params = append(query.Params, [2]string{ident, typ})
+		case sqlFileAnnotationParams:
+			if value == "" {
+				return nil, fmt.Errorf("line %d: expects `-- params: Type`, got %s", line, quote(trimmed))
+			}
+			query.Params = append(query.Params, [2]string{sqlFileParamsIdent, value})
+			if !hasOption(query.Options, "NAMED") {
+				query.Options = append(query.Options, "NAMED")
+			}
+		case sqlFileAnnotationResult:
+			if query.Command != sqlFileCmdOne && query.Command != sqlFileCmdMany {
+				return nil, fmt.Errorf("line %d: `-- result:` is only applicable to %s and %s queries",
+					line, sqlFileCmdOne, sqlFileCmdMany)
+			}
+			query.Result = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	if len(file.Queries) == 0 {
+		return nil, fmt.Errorf("no query annotated with `-- name:` found")
+	}
+	for _, query := range file.Queries {
+		if (query.Command == sqlFileCmdOne || query.Command == sqlFileCmdMany) && query.Result == "" {
+			return nil, fmt.Errorf("line %d: query %s expects a `-- result:` annotation", query.Line, quote(query.Name))
+		}
+	}
+	return file, nil
+}
+
+// Schema returns the Go source of the schema interface, and the line of its type declaration.
+func (file *SQLFile) Schema(pkg string, ident string, source string) ([]byte, int) {
+	var buf bytes.Buffer
+	buf.WriteString("package " + pkg + "\n\n")
+	buf.WriteString("import (\n\t\"context\"\n")
+	for _, query := range file.Queries {
+		if query.Command == sqlFileCmdExecResult {
+			// the methods of :execresult queries return sql.Result
+			buf.WriteString("\t\"database/sql\"\n")
+			break
+		}
+	}
+	for _, imp := range file.Imports {
+		buf.WriteString("\t" + parseImport(imp) + "\n")
+	}
+	buf.WriteString(")\n\n")
+	line := bytes.Count(buf.Bytes(), []byte("\n")) + 2
+	buf.WriteString(file.Decl(ident, source))
+	return buf.Bytes(), line
+}
+
+// Decl returns the declaration of the schema interface, whose methods are annotated in the format of sqlx mode.
+func (file *SQLFile) Decl(ident string, source string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s is the schema of the queries in %s.\n", ident, source)
+	fmt.Fprintf(&buf, "type %s interface {\n", ident)
+	for i, query := range file.Queries {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "\t// %s %s\n", query.Name, concat(query.Meta(), " "))
+		for _, line := range split(query.Query, "\n") {
+			buf.WriteString(strings.TrimRight("\t// "+line, " ") + "\n")
+		}
+		fmt.Fprintf(&buf, "\t%s(ctx context.Context", query.Name)
+		for _, param := range query.Params {
+			fmt.Fprintf(&buf, ", %s %s", param[0], param[1])
+		}
+		buf.WriteString(") " + query.Out() + "\n")
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// Meta returns the operation and options of the method comment.
+func (query *SQLQuery) Meta() []string {
+	var meta []string
+	switch query.Command {
+	case sqlFileCmdOne:
+		meta = []string{sqlxOpQuery, "ONE"}
+	case sqlFileCmdMany:
+		meta = []string{sqlxOpQuery, "MANY"}
+	default:
+		meta = []string{sqlxOpExec}
+	}
+	for _, opt := range query.Options {
+		if !hasOption(meta, opt) {
+			meta = append(meta, opt)
+		}
+	}
+	return meta
+}
+
+// Out returns the results of the method.
+func (query *SQLQuery) Out() string {
+	switch query.Command {
+	case sqlFileCmdOne:
+		return "(" + query.Result + ", error)"
+	case sqlFileCmdMany:
+		if hasPrefix(query.Result, "[]") {
+			return "(" + query.Result + ", error)"
+		}
+		return "([]" + query.Result + ", error)"
+	case sqlFileCmdExecResult:
+		return "(sql.Result, error)"
+	default:
+		return "error"
+	}
+}
+
+func isSQLFile(file string) bool {
+	return toLower(filepath.Ext(file)) == sqlFileExt
+}
+
+// sqlFileIdent derives the name of the schema interface from the name of the .sql file,
+// e.g. "user_queries.sql" becomes "UserQueries".
+func sqlFileIdent(file string) string {
+	var ident string
+	for _, word := range sqlFileIdentRe.Split(trimSuffix(filepath.Base(file), filepath.Ext(file)), -1) {
+		if word != "" {
+			ident += toUpper(word[:1]) + word[1:]
+		}
+	}
+	if ident == "" || !token.IsIdentifier(ident) {
+		return "Queries"
+	}
+	return ident
+}
+
+// buildSqlFile generates the schema interface of a .sql file together with its sqlx implementation.
+func (builder *CliBuilder) buildSqlFile(w io.Writer) error {
+	file, err := ParseSQLFile(builder.doc)
+	if err != nil {
+		return fmt.Errorf("ParseSQLFile(%s): %w", quote(builder.file), err)
+	}
+	pkg := builder.pkg
+	if pkg == "" {
+		if pkg = file.Package; pkg == "" {
+			return fmt.Errorf("ParseSQLFile(%s): package name required, "+
+				"specify it with the `-- package:` annotation or the $GOPACKAGE environment variable", quote(builder.file))
+		}
+	}
+	ident := file.Type
+	if ident == "" {
+		ident = sqlFileIdent(builder.file)
+	}
+	if !token.IsIdentifier(ident) {
+		return fmt.Errorf("ParseSQLFile(%s): invalid type name %s", quote(builder.file), quote(ident))
+	}
+	schema, line := file.Schema(pkg, ident, filepath.Base(builder.file))
+	imports := make([]string, 0, len(builder.imports)+len(file.Imports))
+	imports = append(imports, builder.imports...)
+	for _, imp := range file.Imports {
+		imports = append(imports, parseImport(imp))
+	}
+	schemaBuilder := *builder
+	schemaBuilder.pkg = pkg
+	schemaBuilder.imports = imports
+	schemaBuilder.doc = schema
+	schemaBuilder.pos = line - 1
+	var buf bytes.Buffer
+	if err = schemaBuilder.buildSqlx(&buf); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	buf.WriteString(file.Decl(ident, filepath.Base(builder.file)))
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,210 @@
+package gen
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSQLFile(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		doc, err := os.ReadFile(filepath.Join("testdata", "sqlx", "queries.sql"))
+		if err != nil {
+			t.Fatalf("read: %s", err)
+		}
+		file, err := ParseSQLFile(doc)
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+		if file.Package != "test" || file.Type != "" || !reflect.DeepEqual(file.Imports, []string{`gofmt "fmt"`}) {
+			t.Errorf("parse: unexpected file annotations %q, %q, %q", file.Package, file.Type, file.Imports)
+		}
+		type expect struct {
+			Name   string
+			Meta   []string
+			Params [][2]string
+			Out    string
+			Query  string
+		}
+		expects := []*expect{
+			{
+				Name:   "CreateUser",
+				Meta:   []string{"EXEC", "NAMED"},
+				Params: [][2]string{{"arg", "map[string]any"}},
+				Out:    "(sql.Result, error)",
+				Query:  "INSERT INTO users (name, email) VALUES (:name, :email);",
+			},
+			{
+				Name:   "GetUser",
+				Meta:   []string{"QUERY", "ONE"},
+				Params: [][2]string{{"id", "int64"}},
+				Out:    "(*struct{ Name gofmt.Stringer }, error)",
+				Query:  "SELECT name\n  FROM users\n WHERE id = ?;",
+			},
+			{
+				Name:   "ListUsers",
+				Meta:   []string{"QUERY", "MANY", "BIND"},
+				Params: [][2]string{{"names", "[]string"}},
+				Out:    "([]*struct{ Name string }, error)",
+				Query:  "SELECT name FROM users WHERE name IN {{ bind $.names }};",
+			},
+			{
+				Name:  "DeleteUsers",
+				Meta:  []string{"EXEC"},
+				Out:   "error",
+				Query: "DELETE FROM users;",
+			},
+		}
+		if len(file.Queries) != len(expects) {
+			t.Fatalf("parse: %d queries != %d", len(file.Queries), len(expects))
+		}
+		for i, query := range file.Queries {
+			got := &expect{
+				Name:   query.Name,
+				Meta:   query.Meta(),
+				Params: query.Params,
+				Out:    query.Out(),
+				Query:  query.Query,
+			}
+			if !reflect.DeepEqual(got, expects[i]) {
+				t.Errorf("parse: %+v != %+v", got, expects[i])
+			}
+		}
+	})
+	t.Run("fail", func(t *testing.T) {
+		for _, testcase := range []struct{ doc, err string }{
+			{"", "no query"},
+			{"SELECT 1;\n-- name: A :exec\nSELECT 1;", "before the first"},
+			{"-- name: A\nSELECT 1;", "expects `-- name: Name :command`"},
+			{"-- name: a-b :exec\nSELECT 1;", "invalid query name"},
+			{"-- name: A :exec\nSELECT 1;\n-- name: A :exec\nSELECT 1;", "duplicate query name"},
+			{"-- name: A :copyfrom\nSELECT 1;", "unsupported command"},
+			{"-- name: A :exec\n-- name: B :exec\nSELECT 1;", "has no sql statement"},
+			{"-- name: A :exec\n-- param: id\nSELECT 1;", "expects `-- param: name type`"},
+			{"-- name: A :exec\n-- params:\nSELECT 1;", "expects `-- params: Type`"},
+			{"-- name: A :exec\n-- result: int\nSELECT 1;", "only applicable"},
+			{"-- name: A :one\nSELECT 1;", "expects a `-- result:` annotation"},
+		} {
+			if _, err := ParseSQLFile([]byte(testcase.doc)); err == nil || !strings.Contains(err.Error(), testcase.err) {
+				t.Errorf("parse: expects error %q for %q, got %v", testcase.err, testcase.doc, err)
+			}
+		}
+	})
+}
+
+func TestSQLFileSchema(t *testing.T) {
+	for _, testcase := range []struct {
+		doc       string
+		sqlImport bool
+	}{
+		{"-- name: A :execresult\nDELETE FROM users;", true},
+		{"-- name: A :exec\nDELETE FROM users;", false},
+	} {
+		file, err := ParseSQLFile([]byte(testcase.doc))
+		if err != nil {
+			t.Fatalf("parse: %s", err)
+		}
+		schema, _ := file.Schema("test", "Queries", "queries.sql")
+		f, err := parser.ParseFile(token.NewFileSet(), "queries.go", schema, parser.ImportsOnly)
+		if err != nil {
+			t.Fatalf("schema: %s", err)
+		}
+		var imported bool
+		for _, imp := range f.Imports {
+			imported = imported || imp.Path.Value == `"database/sql"`
+		}
+		if imported != testcase.sqlImport {
+			t.Errorf("schema: database/sql imported = %v for %q:\n%s", imported, testcase.doc, schema)
+		}
+	}
+}
+
+func TestSqlFileIdent(t *testing.T) {
+	for file, ident := range map[string]string{
+		"queries.sql":              "Queries",
+		"/path/to/user_query.sql":  "UserQuery",
+		"user-account.queries.SQL": "UserAccountQueries",
+		"0001.sql":                 "Queries",
+	} {
+		if got := sqlFileIdent(file); got != ident {
+			t.Errorf("ident: %q != %q", got, ident)
+		}
+	}
+}
+
+func TestBuildSqlFile(t *testing.T) {
+	const (
+		testPk  = "test"
+		testSQL = "queries.sql"
+	)
+	var (
+		testDir = filepath.Join("testdata", "sqlx")
+		genFile = testPk + "." + strings.ReplaceAll(t.Name(), "/", "_") + ".go"
+	)
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Errorf("getwd: %s", err)
+		return
+	}
+	defer func() {
+		if err = os.Chdir(pwd); err != nil {
+			t.Errorf("chdir: %s", err)
+			return
+		}
+	}()
+	if err = os.Chdir(testDir); err != nil {
+		t.Errorf("chdir: %s", err)
+		return
+	}
+	doc, err := os.ReadFile(testSQL)
+	if err != nil {
+		t.Errorf("build: error reading %s file => %s", testSQL, err)
+		return
+	}
+	testDirAbs, err := os.Getwd()
+	if err != nil {
+		t.Errorf("getwd: %s", err)
+		return
+	}
+	newBuilder := func(pkg string, doc []byte) *CliBuilder {
+		return NewCliBuilder(ModeSqlx).
+			WithFeats([]string{FeatureSqlxNoRt}).
+			WithPkg(pkg).
+			WithPwd(testDirAbs).
+			WithFile(filepath.Join(testDirAbs, testSQL), doc)
+	}
+	t.Run("success", func(t *testing.T) {
+		if err := runTest(genFile, newBuilder("", doc)); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("success_gopackage", func(t *testing.T) {
+		if err := runTest(genFile, newBuilder(testPk, []byte("-- type: UserQuery\n-- name: A :exec\nSELECT 1;"))); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("fail_no_package", func(t *testing.T) {
+		if err := runTest(genFile, newBuilder("", []byte("-- name: A :exec\nSELECT 1;"))); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "package name required") {
+			t.Errorf("build: expects package name required error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_invalid_type", func(t *testing.T) {
+		if err := runTest(genFile, newBuilder(testPk, []byte("-- type: User Query\n-- name: A :exec\nSELECT 1;"))); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "invalid type name") {
+			t.Errorf("build: expects invalid type name error, got => %s", err)
+			return
+		}
+	})
+}
@@ -8,10 +8,14 @@ import (
 	"go/parser"
 	"go/token"
 	"io"
+	"regexp"
 	"strings"
 	"text/template"
+	"time"
 
 	_ "embed"
+
+	tok "github.com/x5iu/defc/runtime/token"
 )
 
 const (
@@ -30,6 +34,11 @@ const (
 	FeatureSqlxFuture      = "sqlx/future"
 	FeatureSqlxCallback    = "sqlx/callback"
 	FeatureSqlxAnyCallback = "sqlx/any-callback"
+	FeatureSqlxExplain     = "sqlx/explain"
+	FeatureSqlxInterpolate = "sqlx/interpolate"
+	FeatureSqlxOverride    = "sqlx/override"
+	FeatureSqlxContextTx   = "sqlx/context-tx"
+	FeatureSqlxCache       = "sqlx/cache"
 )
 
 func (builder *CliBuilder) buildSqlx(w io.Writer) error {
@@ -44,6 +53,8 @@ type sqlxContext struct {
 	Package         string
 	BuildTags       []string
 	Ident           string
+	Generics        map[string]ast.Expr
+	TypeParams      *ast.FieldList
 	Methods         []*Method
 	Embeds          []ast.Expr
 	WithTx          bool
@@ -56,12 +67,23 @@ type sqlxContext struct {
 	Pwd             string
 	Doc             Doc
 	Template        string
+	Timeout         time.Duration
+
+	headers map[string]string
+}
+
+func (ctx *sqlxContext) readHeader(header string) (string, error) {
+	if processed, ok := ctx.headers[header]; ok {
+		return processed, nil
+	}
+	return readHeader(header, ctx.Pwd)
 }
 
 func (ctx *sqlxContext) Build(w io.Writer) error {
 	const (
 		constbindOption = "CONSTBIND"
 		bindOption      = "BIND"
+		builderOption   = "BUILDER"
 	)
 
 	var fixedMethods []*Method = nil
@@ -78,12 +100,56 @@ func (ctx *sqlxContext) Build(w io.Writer) error {
 				quote(method.Ident))
 		}
 
+		if hasOption(opts, builderOption) {
+			for _, option := range []string{"CONST", constbindOption, bindOption, "NAMED"} {
+				if hasOption(opts, option) {
+					return fmt.Errorf("method %s: BUILDER and %s options are mutually exclusive, please use only one of them",
+						quote(method.Ident), option)
+				}
+			}
+			if method.BuilderArg() == "" {
+				return fmt.Errorf("method %s: BUILDER option expects exactly one argument other than context.Context",
+					quote(method.Ident))
+			}
+			if trimSpace(method.Header) != "" {
+				return fmt.Errorf("method %s: BUILDER option takes the query from its argument, "+
+					"the method should not have a query", quote(method.Ident))
+			}
+		}
+
+		if _, err := method.Timeout(); err != nil {
+			return err
+		}
+		if attemptTimeout, err := method.AttemptTimeout(); err != nil || attemptTimeout > 0 {
+			return fmt.Errorf("method %s: ATTEMPT_TIMEOUT option is only available in api mode", quote(method.Ident))
+		}
+
+		groupColumns, err := method.SqlxGroup()
+		if err != nil {
+			return err
+		}
+		if groupColumns != nil {
+			if err = ctx.checkGroup(method); err != nil {
+				return err
+			}
+		}
+
+		pageColumns, err := method.SqlxPage()
+		if err != nil {
+			return err
+		}
+		if pageColumns != nil {
+			if err = ctx.checkPage(method); err != nil {
+				return err
+			}
+		}
+
 		if method.SingleScan() != "" {
 			if len(method.Out) != 1 {
 				return fmt.Errorf("%s method expects only error returned value when `scan(expr)` option has been specified",
 					quote(method.Ident))
 			}
-		} else {
+		} else if pageColumns == nil {
 			if len(method.Out) > 2 {
 				return fmt.Errorf("%s method expects 2 returned value at most, got %d",
 					quote(method.Ident),
@@ -113,6 +179,65 @@ func (ctx *sqlxContext) Build(w io.Writer) error {
 		ctx.Methods = fixedMethods
 	}
 
+	for _, method := range ctx.Methods {
+		if err := ctx.checkInvalidate(method); err != nil {
+			return err
+		}
+	}
+
+	// Headers are read before generating code, so that errors of #INCLUDE/#SCRIPT commands name their methods,
+	// and commands are only run once for each method.
+	ctx.headers = make(map[string]string, len(ctx.Methods))
+	for _, method := range ctx.Methods {
+		header, err := readHeader(method.Header, ctx.Pwd)
+		if err != nil {
+			return fmt.Errorf("method %s: %w", quote(method.Ident), err)
+		}
+		ctx.headers[method.Header] = header
+		dialects, err := method.SqlxDialects()
+		if err != nil {
+			return err
+		}
+		if dialects != nil && ctx.HasFeature(FeatureSqlxNoRt) {
+			return fmt.Errorf("method %s: `-- dialect:` sections require sqlx/nort feature to be disabled",
+				quote(method.Ident))
+		}
+		for _, dialect := range dialects {
+			if ctx.headers[dialect.Header], err = readHeader(dialect.Header, ctx.Pwd); err != nil {
+				return fmt.Errorf("method %s: %w", quote(method.Ident), err)
+			}
+		}
+		if columns, _ := method.SqlxPage(); columns != nil {
+			if dialects == nil {
+				dialects = []*SqlxDialect{{Header: method.Header}}
+			}
+			for _, dialect := range dialects {
+				// Keyset wraps the whole query into a subquery, which is broken by a second statement.
+				if n := countStatements(ctx.headers[dialect.Header]); n > 1 {
+					return fmt.Errorf("method %s: PAGE option expects a single SELECT statement, got %d statements",
+						quote(method.Ident), n)
+				}
+			}
+		}
+	}
+
+	if ctx.HasFeature(FeatureSqlxExplain) && ctx.HasFeature(FeatureSqlxNoRt) {
+		return fmt.Errorf("sqlx/explain feature requires sqlx/nort feature to be disabled")
+	}
+
+	if ctx.HasFeature(FeatureSqlxInterpolate) {
+		if !ctx.HasFeature(FeatureSqlxLog) {
+			return fmt.Errorf("sqlx/interpolate feature requires sqlx/log feature to be enabled")
+		}
+		if ctx.HasFeature(FeatureSqlxNoRt) {
+			return fmt.Errorf("sqlx/interpolate feature requires sqlx/nort feature to be disabled")
+		}
+	}
+
+	if ctx.HasFeature(FeatureSqlxContextTx) && ctx.HasFeature(FeatureSqlxNoRt) {
+		return fmt.Errorf("sqlx/context-tx feature requires sqlx/nort feature to be disabled")
+	}
+
 	var bindInvoked bool
 	// Since the text/template standard library does not provide a specific error type, we can only determine whether
 	// the bind function has been invoked in the template through this rudimentary way.
@@ -147,7 +272,9 @@ func (ctx *sqlxContext) Build(w io.Writer) error {
 		}
 		if useBind {
 			for _, method := range ctx.Methods {
-				if !hasOption(method.SqlxOptions(), bindOption) && !hasOption(method.SqlxOptions(), namedOption) {
+				if opts := method.SqlxOptions(); !hasOption(opts, bindOption) &&
+					!hasOption(opts, namedOption) &&
+					!hasOption(opts, builderOption) {
 					method.Meta += " " + bindOption
 				}
 			}
@@ -161,6 +288,120 @@ func (ctx *sqlxContext) Build(w io.Writer) error {
 	return nil
 }
 
+// checkPage checks the method with the `PAGE=keyset(...)` option, which should look like:
+//
+//	// ListUsers QUERY MANY PAGE=keyset(id)
+//	// SELECT * FROM user WHERE status = ?;
+//	ListUsers(ctx context.Context, status string, cursor string, size int) ([]*User, string, error)
+func (ctx *sqlxContext) checkPage(method *Method) error {
+	if ctx.HasFeature(FeatureSqlxNoRt) {
+		return fmt.Errorf("method %s: PAGE option requires sqlx/nort feature to be disabled", quote(method.Ident))
+	}
+	if method.SqlxOperation() != sqlxOpQuery {
+		return fmt.Errorf("method %s: PAGE option is only available to QUERY methods", quote(method.Ident))
+	}
+	opts := method.SqlxOptions()
+	for _, option := range []string{"ONE", "NAMED"} {
+		if hasOption(opts, option) {
+			return fmt.Errorf("method %s: PAGE and %s options are mutually exclusive", quote(method.Ident), option)
+		}
+	}
+	if method.SingleScan() != "" || method.WrapFunc() != "" {
+		return fmt.Errorf("method %s: PAGE option cannot be used with SCAN or WRAP options", quote(method.Ident))
+	}
+	if len(method.Out) != 3 || !isSlice(method.Out[0]) || !isIdent(method.Out[1], "string") {
+		return fmt.Errorf("method %s: PAGE option expects ([]T, string, error) returned values, "+
+			"where the string is the cursor of the next page", quote(method.Ident))
+	}
+	args := method.SqlxPageArgs()
+	if len(args) != 2 || !isIdent(method.In[args[0]], "string") || !isIdent(method.In[args[1]], "int") {
+		return fmt.Errorf("method %s: PAGE option expects the last two arguments to be the cursor (string) "+
+			"and the page size (int)", quote(method.Ident))
+	}
+	return nil
+}
+
+// countStatements counts the non-empty statements of query separated by semicolons, in the same way as the Split
+// function of the runtime package splits queries before executing them.
+func countStatements(query string) (n int) {
+	var statement bool
+	for _, token := range tok.SplitTokens(query) {
+		switch token {
+		case ";":
+			if statement {
+				n++
+			}
+			statement = false
+		case tok.Space:
+		default:
+			statement = true
+		}
+	}
+	if statement {
+		n++
+	}
+	return n
+}
+
+// checkGroup checks the method with the `GROUP=id` option, whose rows are folded into parents by sqlx.Group.
+func (ctx *sqlxContext) checkGroup(method *Method) error {
+	if !ctx.HasFeature(FeatureSqlxFuture) {
+		return fmt.Errorf("method %s: GROUP option requires sqlx/future feature to be enabled", quote(method.Ident))
+	}
+	if columns, _ := method.SqlxPage(); columns != nil {
+		return fmt.Errorf("method %s: GROUP and PAGE options are mutually exclusive, "+
+			"since pages would be counted in rows instead of groups", quote(method.Ident))
+	}
+	if method.SqlxOperation() != sqlxOpQuery || len(method.Out) != 2 || !isSlice(method.Out[0]) {
+		return fmt.Errorf("method %s: GROUP option is only available to QUERY methods returning ([]T, error)",
+			quote(method.Ident))
+	}
+	if hasOption(method.SqlxOptions(), "ONE") {
+		return fmt.Errorf("method %s: GROUP and ONE options are mutually exclusive", quote(method.Ident))
+	}
+	if method.SingleScan() != "" || method.WrapFunc() != "" {
+		return fmt.Errorf("method %s: GROUP option cannot be used with SCAN or WRAP options", quote(method.Ident))
+	}
+	return nil
+}
+
+// checkInvalidate checks the method with the `INVALIDATE=GetUser,ListUsers` option, whose unqualified names should be
+// QUERY methods of the schema, while qualified names such as `Other.GetUser` refer to methods of other schemas.
+func (ctx *sqlxContext) checkInvalidate(method *Method) error {
+	names, err := method.SqlxInvalidate()
+	if err != nil || names == nil {
+		return err
+	}
+	if !ctx.HasFeature(FeatureSqlxCache) {
+		return fmt.Errorf("method %s: INVALIDATE option requires sqlx/cache feature to be enabled", quote(method.Ident))
+	}
+	if method.SqlxOperation() != sqlxOpExec {
+		return fmt.Errorf("method %s: INVALIDATE option is only available to EXEC methods", quote(method.Ident))
+	}
+names:
+	for _, name := range names {
+		if contains(name, ".") {
+			continue
+		}
+		for _, other := range ctx.Methods {
+			if other.Ident == name {
+				if !ctx.IsCached(other) {
+					return fmt.Errorf("method %s: INVALIDATE option names method %s, whose results are not cached",
+						quote(method.Ident), quote(name))
+				}
+				continue names
+			}
+		}
+		return fmt.Errorf("method %s: INVALIDATE option names unknown method %s", quote(method.Ident), quote(name))
+	}
+	return nil
+}
+
+func isIdent(node ast.Expr, name string) bool {
+	ident, ok := node.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
 func (ctx *sqlxContext) HasFeature(feature string) bool {
 	for _, current := range ctx.Features {
 		if current == feature {
@@ -170,6 +411,84 @@ func (ctx *sqlxContext) HasFeature(feature string) bool {
 	return false
 }
 
+// GenericsRepr is built from the type parameter list like fakeContext.GenericsRepr, so that parameters
+// sharing a constraint (`[T any, ID comparable]`) keep their order.
+func (ctx *sqlxContext) GenericsRepr(withType bool) string {
+	if ctx.TypeParams == nil || len(ctx.TypeParams.List) == 0 {
+		return ""
+	}
+	if withType {
+		return ctx.Doc.Repr(ctx.TypeParams)
+	}
+	names := make([]string, 0, len(ctx.Generics))
+	for _, param := range ctx.TypeParams.List {
+		for _, name := range param.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return "[" + concat(names, ", ") + "]"
+}
+
+// HasDialects reports whether any method has `-- dialect:` sections.
+func (ctx *sqlxContext) HasDialects() bool {
+	for _, method := range ctx.Methods {
+		if dialects, _ := method.SqlxDialects(); dialects != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCached reports whether the results of method are looked up in (and stored into) the cache of the core, which is
+// the case of QUERY methods returning values when the sqlx/cache feature is enabled. The arguments of BUILDER methods
+// hide their queries, so that they are not cached.
+func (ctx *sqlxContext) IsCached(method *Method) bool {
+	return ctx.HasFeature(FeatureSqlxCache) &&
+		method.SqlxOperation() == sqlxOpQuery &&
+		len(method.Out) > 1 &&
+		!hasOption(method.SqlxOptions(), "BUILDER")
+}
+
+// CacheName returns the name of method given to the cache of the core, which is qualified by the schema, so that
+// schemas sharing a core do not share cached results.
+func (ctx *sqlxContext) CacheName(method string) string {
+	if contains(method, ".") {
+		return method
+	}
+	return ctx.Ident + "." + method
+}
+
+// InvalidatedCaches returns the qualified names of the methods invalidated by the `INVALIDATE=` option of method.
+func (ctx *sqlxContext) InvalidatedCaches(method *Method) []string {
+	names, _ := method.SqlxInvalidate()
+	for i, name := range names {
+		names[i] = ctx.CacheName(name)
+	}
+	return names
+}
+
+// MethodTimeout returns the Go expression of the timeout of method, which is its `TIMEOUT=` option or the default
+// timeout given by the --timeout flag, or an empty string if the method has no timeout.
+func (ctx *sqlxContext) MethodTimeout(method *Method) string {
+	timeout, _ := method.Timeout()
+	if timeout == 0 {
+		timeout = ctx.Timeout
+	}
+	if timeout == 0 {
+		return ""
+	}
+	return durationExpr(timeout)
+}
+
+func (ctx *sqlxContext) hasTimeout() bool {
+	for _, method := range ctx.Methods {
+		if ctx.MethodTimeout(method) != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (ctx *sqlxContext) MergedImports() (imports []string) {
 	imports = []string{
 		quote("fmt"),
@@ -185,7 +504,7 @@ func (ctx *sqlxContext) MergedImports() (imports []string) {
 		imports = append(imports, quote("github.com/jmoiron/sqlx"))
 	}
 
-	if ctx.HasFeature(FeatureSqlxLog) {
+	if ctx.HasFeature(FeatureSqlxLog) || ctx.HasFeature(FeatureSqlxExplain) || ctx.hasTimeout() {
 		imports = append(imports, quote("time"))
 	}
 
@@ -198,11 +517,18 @@ func (ctx *sqlxContext) MergedImports() (imports []string) {
 			quote("bytes"),
 			quote("database/sql/driver"))
 	} else {
-		if len(ctx.Methods) > 0 {
+		if len(ctx.Methods) > 0 || (ctx.WithTx && ctx.HasFeature(FeatureSqlxContextTx)) {
 			imports = append(imports, parseImport("__rt github.com/x5iu/defc/runtime"))
 		}
 	}
 
+	if ctx.HasFeature(FeatureSqlxOverride) {
+		imports = append(imports, quote("io/fs"))
+		if !ctx.HasFeature(FeatureSqlxNoRt) {
+			imports = append(imports, quote("strings"))
+		}
+	}
+
 	for _, imp := range ctx.Imports {
 		if !in(imports, imp) {
 			imports = append(imports, parseImport(imp))
@@ -306,16 +632,19 @@ inspectType:
 	}
 
 	return &sqlxContext{
-		Package:   builder.pkg,
-		BuildTags: parseBuildTags(builder.doc),
-		Ident:     typeSpec.Name.Name,
-		Methods:   typeMap(methods, builder.doc.InspectMethod),
-		Embeds:    embeds,
-		Features:  sqlxFeatures,
-		Imports:   builder.imports,
-		Funcs:     builder.funcs,
-		Doc:       builder.doc,
-		Template:  builder.template,
+		Package:    builder.pkg,
+		BuildTags:  parseBuildTags(builder.doc),
+		Ident:      typeSpec.Name.Name,
+		Generics:   inspectGenerics(typeSpec),
+		TypeParams: typeSpec.TypeParams,
+		Methods:    typeMap(methods, builder.doc.InspectMethod),
+		Embeds:     embeds,
+		Features:   sqlxFeatures,
+		Imports:    builder.imports,
+		Funcs:      builder.funcs,
+		Doc:        builder.doc,
+		Template:   builder.template,
+		Timeout:    builder.timeout,
 	}, nil
 }
 
@@ -349,28 +678,13 @@ func readHeader(header string, pwd string) (string, error) {
 		args := splitArgs(text)
 
 		// parse #include/#script command which should be placed in a new line
-		if len(args) == 2 && toUpper(args[0]) == sqlxCmdInclude {
+		if len(args) >= 2 && toUpper(args[0]) == sqlxCmdInclude {
 			// unquote path pattern if it is quoted
-			path := unquote(args[1])
-			if !isAbs(path) {
-				path = join(pwd, path)
-			}
-			// get filenames that match the pattern
-			matches, err := glob(path)
+			content, err := readInclude(unquote(args[1]), args[2:], pwd)
 			if err != nil {
 				return "", err
 			}
-			// read each file into buffer
-			for _, path = range matches {
-				if !isAbs(path) {
-					path = join(pwd, path)
-				}
-				content, err := read(path)
-				if err != nil {
-					return "", fmt.Errorf("os.ReadFile(%s): %w", quote(path), err)
-				}
-				buf.WriteString(string(content))
-			}
+			buf.WriteString(content)
 		} else if len(args) > 1 && toUpper(args[0]) == sqlxCmdScript {
 			output, err := runCommand(args[1:])
 			if err != nil {
@@ -388,6 +702,101 @@ func readHeader(header string, pwd string) (string, error) {
 	return buf.String(), nil
 }
 
+// readInclude reads the files matching the path pattern of an #INCLUDE command, or only the section of them named
+// after '#', e.g. "user.sql#GetUser", which spans from the `-- name: GetUser` line to the next `-- name:` line.
+// Parameters in the form of name=expr replace the references to $.name in the included content with expr.
+func readInclude(pattern string, params []string, pwd string) (string, error) {
+	path, section, hasSection := cut(pattern, "#")
+	if !isAbs(path) {
+		path = join(pwd, path)
+	}
+	// get filenames that match the pattern
+	matches, err := glob(path)
+	if err != nil {
+		return "", err
+	}
+	if hasSection && len(matches) == 0 {
+		return "", fmt.Errorf("#INCLUDE %s: no file matches %s", quote(pattern), quote(path))
+	}
+	var (
+		buf   bytes.Buffer
+		found string
+	)
+	// read each file into buffer
+	for _, path = range matches {
+		content, err := read(path)
+		if err != nil {
+			return "", fmt.Errorf("os.ReadFile(%s): %w", quote(path), err)
+		}
+		if !hasSection {
+			buf.WriteString(string(content))
+			continue
+		}
+		sections, err := readSections(content)
+		if err != nil {
+			return "", fmt.Errorf("#INCLUDE %s: %s: %w", quote(pattern), path, err)
+		}
+		if body, ok := sections[section]; ok {
+			if found != "" {
+				return "", fmt.Errorf("#INCLUDE %s: duplicate section %s found in %s and %s",
+					quote(pattern), quote(section), found, path)
+			}
+			found = path
+			buf.WriteString(body)
+		}
+	}
+	if hasSection && found == "" {
+		return "", fmt.Errorf("#INCLUDE %s: section %s not found", quote(pattern), quote(section))
+	}
+	content := buf.String()
+	for _, param := range params {
+		name, expr, ok := cut(param, "=")
+		if !ok || !token.IsIdentifier(name) || expr == "" {
+			return "", fmt.Errorf("#INCLUDE %s: invalid parameter %s, expects name=expr", quote(pattern), quote(param))
+		}
+		content = regexp.MustCompile(`\$\.`+name+`\b`).ReplaceAllLiteralString(content, expr)
+	}
+	return content, nil
+}
+
+// readSections splits content into sections delimited by `-- name: Name` lines, line comments within sections
+// (such as the `-- param:` annotations of .sql files) are dropped.
+func readSections(content []byte) (map[string]string, error) {
+	var (
+		sections = make(map[string]string)
+		name     string
+		body     []string
+	)
+	finish := func() {
+		if name != "" {
+			sections[name] = trimSpace(concat(body, "\n"))
+		}
+		body = nil
+	}
+	for line, text := range split(string(content), "\n") {
+		text = strings.TrimRight(text, " \t\r")
+		trimmed := trimSpace(text)
+		if matches := sqlFileAnnotationRe.FindStringSubmatch(trimmed); matches != nil && matches[1] == sqlFileAnnotationName {
+			finish()
+			if fields := strings.Fields(matches[2]); len(fields) > 0 {
+				name = fields[0]
+			} else {
+				return nil, fmt.Errorf("line %d: empty section name", line+1)
+			}
+			if _, duplicate := sections[name]; duplicate {
+				return nil, fmt.Errorf("line %d: duplicate section %s", line+1, quote(name))
+			}
+			sections[name] = ""
+			continue
+		}
+		if name != "" && !hasPrefix(trimmed, "--") {
+			body = append(body, text)
+		}
+	}
+	finish()
+	return sections, nil
+}
+
 func hasOption(opts []string, opt string) bool {
 	for _, o := range opts {
 		if o == toUpper(opt) {
@@ -408,16 +817,17 @@ func (ctx *sqlxContext) genSqlxCode(w io.Writer) error {
 			"hasOption":     hasOption,
 			"isSlice":       isSlice,
 			"isPointer":     isPointer,
+			"elem":          elem,
 			"indirect":      indirect,
 			"deselect":      deselect,
-			"readHeader":    func(header string) (string, error) { return readHeader(header, ctx.Pwd) },
+			"readHeader":    ctx.readHeader,
 			"isContextType": func(ident string, expr ast.Expr) bool { return ctx.Doc.IsContextType(ident, expr) },
 			"sub":           func(x, y int) int { return x - y },
 			"getRepr":       func(node ast.Node) string { return ctx.Doc.Repr(node) },
 			"isQuery":       func(op string) bool { return op == sqlxOpQuery },
 			"isExec":        func(op string) bool { return op == sqlxOpExec },
 			"constBindSQL": func(header string) (string, error) {
-				processed, err := readHeader(header, ctx.Pwd)
+				processed, err := ctx.readHeader(header)
 				if err != nil {
 					return "", err
 				}
@@ -428,7 +838,7 @@ func (ctx *sqlxContext) genSqlxCode(w io.Writer) error {
 				return result.SQL, nil
 			},
 			"constBindArgs": func(header string) ([]string, error) {
-				processed, err := readHeader(header, ctx.Pwd)
+				processed, err := ctx.readHeader(header)
 				if err != nil {
 					return nil, err
 				}
@@ -3,10 +3,12 @@ package gen
 import (
 	"bufio"
 	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestBuildSqlx(t *testing.T) {
@@ -219,4 +221,492 @@ func TestBuildSqlx(t *testing.T) {
 			return
 		}
 	})
+	t.Run("success_explain", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxExplain})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxLog, FeatureSqlxExplain})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("fail_explain_nort", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxNoRt, FeatureSqlxExplain})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			"sqlx/explain feature requires sqlx/nort feature to be disabled") {
+			t.Errorf("build: expects ExplainNoRt error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_interpolate", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxLog, FeatureSqlxInterpolate})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("fail_interpolate_no_log", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxInterpolate})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			"sqlx/interpolate feature requires sqlx/log feature to be enabled") {
+			t.Errorf("build: expects InterpolateNoLog error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_override", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxNoRt, FeatureSqlxOverride})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxLog, FeatureSqlxOverride}).
+			WithTemplate("")
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("success_dialect", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxLog, FeatureSqlxOverride})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture}).WithTemplate("")
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxNoRt})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			"`-- dialect:` sections require sqlx/nort feature to be disabled") {
+			t.Errorf("build: expects DialectNoRt error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_dialect_duplicate", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats(nil)
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), `method "First": duplicate dialect "mysql"`) {
+			t.Errorf("build: expects DuplicateDialect error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_builder", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxLog, FeatureSqlxIn, FeatureSqlxOverride}).WithTemplate("")
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("fail_builder_bind", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "BUILDER and BIND options are mutually exclusive") {
+			t.Errorf("build: expects BuilderBind error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_builder_args", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "BUILDER option expects exactly one argument other than context.Context") {
+			t.Errorf("build: expects BuilderArgs error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_page", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxLog, FeatureSqlxRebind})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxIn}).WithTemplate("")
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxNoRt})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "PAGE option requires sqlx/nort feature to be disabled") {
+			t.Errorf("build: expects PageNoRt error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_page_returns", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats(nil)
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "PAGE option expects ([]T, string, error) returned values") {
+			t.Errorf("build: expects PageReturns error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_page_statements", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats(nil)
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "PAGE option expects a single SELECT statement, got 2 statements") {
+			t.Errorf("build: expects PageStatements error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_group", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxNoRt})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxLog, FeatureSqlxCallback}).WithTemplate("")
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats(nil)
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "GROUP option requires sqlx/future feature to be enabled") {
+			t.Errorf("build: expects GroupFuture error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_group_one", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "GROUP option is only available to QUERY methods returning ([]T, error)") {
+			t.Errorf("build: expects GroupOne error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_timeout", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxNoRt})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxLog}).WithTemplate("").WithTimeout(time.Second)
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("fail_attempt_timeout", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "ATTEMPT_TIMEOUT option is only available in api mode") {
+			t.Errorf("build: expects AttemptTimeout error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_context_tx", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxContextTx})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxLog, FeatureSqlxExplain, FeatureSqlxContextTx}).WithTemplate("")
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxContextTx, FeatureSqlxNoRt})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "sqlx/context-tx feature requires sqlx/nort feature to be disabled") {
+			t.Errorf("build: expects ContextTxNoRt error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_cache", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxCache})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxFuture, FeatureSqlxLog, FeatureSqlxContextTx, FeatureSqlxCache}).WithTemplate("")
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxCache, FeatureSqlxNoRt})
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats(nil)
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "INVALIDATE option requires sqlx/cache feature to be enabled") {
+			t.Errorf("build: expects InvalidateWithoutCache error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_invalidate", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxCache})
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), "INVALIDATE option names unknown method") {
+			t.Errorf("build: expects UnknownInvalidate error, got => %s", err)
+			return
+		}
+	})
+	t.Run("success_generic", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		builder = builder.WithFeats([]string{FeatureSqlxLog, FeatureSqlxCallback, FeatureSqlxOverride}).
+			WithTemplate("")
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("success_batch_callback", func(t *testing.T) {
+		for _, feats := range [][]string{{FeatureSqlxCallback}, {FeatureSqlxAnyCallback}} {
+			builder, ok := newBuilder(t)
+			if !ok {
+				return
+			}
+			var code string
+			builder = builder.WithFeats(feats)
+			if err := runBuildTest(genFile, func(w io.Writer) error {
+				var bf bytes.Buffer
+				if err := builder.Build(&bf); err != nil {
+					return err
+				}
+				code = bf.String()
+				_, err := w.Write(bf.Bytes())
+				return err
+			}); err != nil {
+				t.Errorf("build: %s", err)
+				return
+			}
+			for _, expect := range []string{
+				"any(v0ListProjects[0]).(interface{BatchCallback(",
+				"any(&v0ListProjectValues[0]).(interface{BatchCallback(",
+				"batchCallbackListProjects.BatchCallback(ctx, __imp, v0ListProjects)",
+				"batchCallbackListProjectValues.BatchCallback(context.Background(), __imp, v0ListProjectValues)",
+			} {
+				if !strings.Contains(code, expect) {
+					t.Errorf("build: expects %q in generated code with %v", expect, feats)
+					return
+				}
+			}
+		}
+	})
+	t.Run("success_include_section", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+	})
+	t.Run("success_include_relative", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		// Relative #INCLUDE paths are resolved against the working directory rather than the directory passed to
+		// WithPwd, which is the directory of the package when running `go generate`.
+		builder = builder.WithPwd(filepath.Dir(builder.pwd))
+		var code string
+		if err := runBuildTest(genFile, func(w io.Writer) error {
+			var bf bytes.Buffer
+			if err := builder.Build(&bf); err != nil {
+				return err
+			}
+			code = bf.String()
+			_, err := w.Write(bf.Bytes())
+			return err
+		}); err != nil {
+			t.Errorf("build: %s", err)
+			return
+		}
+		if !strings.Contains(code, "SELECT CURRENT_TIMESTAMP;") {
+			t.Errorf("build: expects the content of test.sql in generated code")
+			return
+		}
+	})
+	t.Run("fail_include_missing_section", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(),
+			`method "GetUser": #INCLUDE "sections.sql#GetUserByName": section "GetUserByName" not found`) {
+			t.Errorf("build: expects MissingSection error, got => %s", err)
+			return
+		}
+	})
+	t.Run("fail_include_duplicate_section", func(t *testing.T) {
+		builder, ok := newBuilder(t)
+		if !ok {
+			return
+		}
+		if err := runTest(genFile, builder); err == nil {
+			t.Errorf("build: expects errors, got nil")
+			return
+		} else if !strings.Contains(err.Error(), `method "GetUser": #INCLUDE "*.sql#duplicate": `) ||
+			!strings.Contains(err.Error(), `duplicate section "duplicate"`) {
+			t.Errorf("build: expects DuplicateSection error, got => %s", err)
+			return
+		}
+	})
+}
+
+func TestReadInclude(t *testing.T) {
+	dir := filepath.Join("testdata", "sqlx")
+	for _, testcase := range []struct {
+		pattern string
+		params  []string
+		expect  string
+		err     string
+	}{
+		{pattern: "test.sql", expect: "SELECT CURRENT_TIMESTAMP;"},
+		{pattern: "missing*.sql", expect: ""},
+		{pattern: "sections.sql#GetUser", expect: "SELECT * FROM user WHERE username = {{ bind $.username }};"},
+		{
+			pattern: "sections.sql#pagination",
+			params:  []string{"limit=$.size", "offset=10"},
+			expect:  "LIMIT {{ bind $.size }} OFFSET {{ bind 10 }}",
+		},
+		{pattern: "missing.sql#GetUser", err: "no file matches"},
+		{pattern: "sections.sql#pagination", params: []string{"limit"}, err: "invalid parameter"},
+		{pattern: "sections.sql#pagination", params: []string{"$.limit=10"}, err: "invalid parameter"},
+	} {
+		content, err := readInclude(testcase.pattern, testcase.params, dir)
+		if testcase.err != "" {
+			if err == nil || !strings.Contains(err.Error(), testcase.err) {
+				t.Errorf("include: expects error %q for %q, got %v", testcase.err, testcase.pattern, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("include: %s", err)
+		} else if trimSpace(content) != testcase.expect {
+			t.Errorf("include: %q != %q", content, testcase.expect)
+		}
+	}
 }
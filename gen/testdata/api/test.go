@@ -6,6 +6,7 @@ package test
 import "C"
 import (
 	"context"
+	"io"
 	"net/http"
 
 	gofmt "fmt"
@@ -147,4 +148,105 @@ type SuccessWithOptions[O any] interface {
 	Run(ctx context.Context) error
 }
 
+//go:generate defc [mode] [output] [features...] TestBuildApi/success_embed
+type SuccessEmbed interface {
+	SuccessWithOptions[*gofmt.Formatter]
+	Options() *gofmt.Formatter
+	Response() Generic[defc.Response, defc.FutureResponse]
+
+	// Run GET https://localhost:port/path
+	Run(ctx context.Context) error
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildApi/success_timeout
+type SuccessTimeout interface {
+	Options() *gofmt.Formatter
+	Response() Generic[defc.Response, defc.FutureResponse]
+
+	// Run GET TIMEOUT=1s ATTEMPT_TIMEOUT=200ms RETRY=3 https://localhost:port/path
+	Run(ctx context.Context) error
+
+	// List GET https://localhost:port/path?page={{ page }}
+	List() ([]string, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildApi/fail_timeout
+type FailTimeout interface {
+	Response() Generic[defc.Response, defc.FutureResponse]
+
+	// Run GET TIMEOUT=-1s https://localhost:port/path
+	Run(ctx context.Context) error
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildApi/success_conditional
+type SuccessConditional interface {
+	Options() *gofmt.Formatter
+	Response() Generic[defc.Response, defc.FutureResponse]
+
+	// Get GET RETRY=3 https://localhost:port/path/{{ $.id }}
+	Get(ctx context.Context, id int64) ([]byte, error)
+
+	// Update PUT https://localhost:port/path/{{ $.id }}
+	Update(ctx context.Context, id int64, body io.Reader) error
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildApi/success_backoff
+type SuccessBackoff interface {
+	Options() *gofmt.Formatter
+	Response() Generic[defc.Response, defc.FutureResponse]
+
+	// Get GET RETRY=3 BACKOFF=exp(100ms,2s) https://localhost:port/path
+	Get(ctx context.Context) ([]byte, error)
+
+	// Delete DELETE RETRY=3 https://localhost:port/path
+	Delete() error
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildApi/success_retry
+type SuccessRetry interface {
+	Options() *gofmt.Formatter
+	Response() Generic[defc.Response, defc.FutureResponse]
+
+	// List GET MANY RETRY=3 https://localhost:port/path?page={{ page }}
+	List(ctx context.Context) ([]string, error)
+
+	// Create POST RETRY=3 https://localhost:port/path
+	Create(body io.Reader) error
+
+	// Search POST MANY https://localhost:port/path?page={{ page }}
+	// Content-Type: application/json
+	//
+	// {"query": {{ $.query }}}
+	Search(ctx context.Context, query string) ([]string, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildApi/success_next_page
+type SuccessNextPage interface {
+	Options() *gofmt.Formatter
+	Response() Generic[defc.Response, defc.FutureResponse]
+
+	// ByCursor GET MANY https://localhost:port/path?cursor={{ cursor }}
+	ByCursor(ctx context.Context) ([]string, error)
+
+	// ByOffset POST MANY https://localhost:port/path?limit={{ $.limit }}&offset={{ or (next "offset") 0 }}
+	// X-Cursor: {{ cursor }}
+	// Content-Type: application/json
+	//
+	// {"limit": {{ $.limit }}}
+	ByOffset(ctx context.Context, limit int) ([]string, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildApi/fail_embed_options
+type FailEmbedOptions interface {
+	SuccessWithOptions[*gofmt.State]
+	Options() *gofmt.Formatter
+	Response() Generic[defc.Response, defc.FutureResponse]
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildApi/fail_embed_no_options
+type FailEmbedNoOptions interface {
+	SuccessWithOptions[*gofmt.Formatter]
+	Response() Generic[defc.Response, defc.FutureResponse]
+}
+
 type Generic[T any, U any] struct{}
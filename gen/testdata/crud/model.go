@@ -0,0 +1,32 @@
+//go:build !no_test
+// +build !no_test
+
+package test
+
+import (
+	"time"
+
+	gosql "database/sql"
+)
+
+// defc:table users
+type User struct {
+	ID        int64            `db:"id"`
+	Name      string           `db:"name"`
+	Email     *string          `db:"email"`
+	Nickname  gosql.NullString `db:"nickname"`
+	Active    bool             `db:"active"`
+	CreatedAt time.Time        `db:"created_at"`
+	Internal  string           `db:"-"`
+	private   string
+}
+
+// defc:table user_roles pk=user_id type=UserRoleQuery autopk=false
+type UserRole struct {
+	UserID int64 `db:"user_id,omitempty"`
+	Role   string
+}
+
+type NotAnnotated struct {
+	ID int64 `db:"id"`
+}
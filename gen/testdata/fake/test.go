@@ -0,0 +1,81 @@
+//go:build !no_test
+// +build !no_test
+
+package test
+
+import (
+	"context"
+	"database/sql"
+	gofmt "fmt"
+	"io"
+)
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/success_sqlx
+type SuccessSqlx interface {
+	gofmt.Stringer
+	io.Closer
+	WithTx(ctx context.Context, fn func(SuccessSqlx) error) error
+
+	// GetUser QUERY ONE
+	// SELECT * FROM user WHERE id = {{ bind $.id }};
+	GetUser(ctx context.Context, id int64) (*User, error)
+
+	// Exec EXEC
+	// {{ range $.sqls }}{{ . }};{{ end }}
+	Exec(ctx context.Context, sqls ...string) (sql.Result, error)
+
+	// Range QUERY ONE
+	// SELECT COUNT(*), MAX(id) FROM user WHERE id BETWEEN {{ bind $.from }} AND {{ bind $.to }};
+	Range(ctx context.Context, from, to int64) (n, max int64, err error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/success_api
+type SuccessApi[I any, R interface{ Err() error }] interface {
+	Inner() I
+	Response() R
+
+	// Get GET https://localhost/users/{{ $.id }}
+	Get(ctx context.Context, id int64) (R, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/success_generic_shared_constraint
+type SuccessGenericSharedConstraint[V, K comparable] interface {
+	Inner() V
+	Load(K) (V, bool)
+	Store(key K, value V)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/success_rpc
+type SuccessRpc interface {
+	Multiply(args chan int) (int, error)
+	unexported()
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/fail_unresolved_embed
+type FailUnresolvedEmbed interface {
+	UndeclaredInterface
+	Get(id int64) (*User, error)
+}
+
+type User struct {
+	ID   int64
+	Name string
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/fail_helper_collision
+type FailHelperCollision interface {
+	Get(id int64) (*User, error)
+	GetCalls() int
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/fail_invocations_collision
+type FailInvocationsCollision interface {
+	Get(id int64) (*User, error)
+	Invocations() []string
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/fail_no_type_decl
+var FailNoTypeDecl struct{}
+
+//go:generate defc [mode] [output] [features...] TestBuildFake/fail_no_iface_type
+type FailNoIfaceType struct{}
@@ -0,0 +1,5 @@
+-- name: duplicate
+SELECT 1;
+
+-- name: duplicate
+SELECT 2;
@@ -0,0 +1,23 @@
+-- Queries of the users table.
+-- package: test
+-- import: gofmt "fmt"
+
+-- name: CreateUser :execresult
+-- params: map[string]any
+INSERT INTO users (name, email) VALUES (:name, :email);
+
+-- name: GetUser :one
+-- param: id int64
+-- result: *struct{ Name gofmt.Stringer }
+SELECT name
+  FROM users
+ WHERE id = ?;
+
+-- name: ListUsers :many bind
+-- param: names []string
+-- result: *struct{ Name string }
+-- comments within queries are dropped
+SELECT name FROM users WHERE name IN {{ bind $.names }};
+
+-- name: DeleteUsers :exec
+DELETE FROM users;
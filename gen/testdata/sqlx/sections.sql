@@ -0,0 +1,10 @@
+-- name: GetUser :one
+-- param: username string
+SELECT * FROM user WHERE username = {{ bind $.username }};
+
+-- name: pagination
+-- a section of a query, parameterized by $.limit and $.offset
+LIMIT {{ bind $.limit }} OFFSET {{ bind $.offset }}
+
+-- name: duplicate
+SELECT 1;
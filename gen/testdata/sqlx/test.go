@@ -11,6 +11,8 @@ import (
 	gofmt "fmt"
 
 	_ "unsafe"
+
+	"github.com/x5iu/defc/runtime/query"
 )
 
 //go:generate defc [mode] [output] [features...] TestBuildSqlx/success
@@ -107,3 +109,326 @@ type FailConstBindBindConflict interface {
 	// SELECT * FROM user WHERE username = ${user.Name} AND age > ${user.Age};
 	GetUser(ctx context.Context, user *User) (*User, error)
 }
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_explain
+type SuccessExplain interface {
+	WithTx(ctx context.Context, f func(tx SuccessExplain) error) error
+
+	// GetUser query one
+	// SELECT * FROM user WHERE username = ?;
+	GetUser(ctx context.Context, username string) (*User, error)
+
+	// InsertUser exec named
+	// INSERT INTO user (name, age) VALUES (:name, :age);
+	InsertUser(ctx context.Context, name string, age int) (sql.Result, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_explain_nort
+type FailExplainNoRt interface {
+	// GetUser query one
+	// SELECT * FROM user WHERE username = ?;
+	GetUser(ctx context.Context, username string) (*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_interpolate
+type SuccessInterpolate interface {
+	// GetUser query one
+	// SELECT * FROM user WHERE username = ?;
+	GetUser(ctx context.Context, username string) (*User, error)
+
+	// InsertUser exec named
+	// INSERT INTO user (name, age) VALUES (:name, :age);
+	InsertUser(ctx context.Context, name string, age int) (sql.Result, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_interpolate_no_log
+type FailInterpolateNoLog interface {
+	// GetUser query one
+	// SELECT * FROM user WHERE username = ?;
+	GetUser(ctx context.Context, username string) (*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_include_section
+type SuccessIncludeSection interface {
+	// GetUser query one bind
+	// #include "sections.sql#GetUser"
+	GetUser(ctx context.Context, username string) (*User, error)
+
+	// ListUsers query many bind
+	// SELECT * FROM user ORDER BY id
+	// #include "sections.sql#pagination" limit=$.size offset=$.offset
+	ListUsers(ctx context.Context, size int, offset int) ([]*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_include_relative
+type SuccessIncludeRelative interface {
+	// GetUser query one bind
+	// #include "sections.sql#GetUser"
+	GetUser(ctx context.Context, username string) (*User, error)
+
+	// Now query one const
+	// #include "test.sql"
+	Now(ctx context.Context) (string, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_include_missing_section
+type FailIncludeMissingSection interface {
+	// GetUser query one bind
+	// #include "sections.sql#GetUserByName"
+	GetUser(ctx context.Context, username string) (*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_include_duplicate_section
+type FailIncludeDuplicateSection interface {
+	// GetUser query one bind
+	// #include "*.sql#duplicate"
+	GetUser(ctx context.Context, username string) (*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_override
+type SuccessOverride interface {
+	WithTx(ctx context.Context, f func(SuccessOverride) error) error
+
+	// GetUser query one
+	// SELECT * FROM user WHERE username = ?;
+	GetUser(ctx context.Context, username string) (*User, error)
+
+	// ListUsers query many bind
+	// SELECT * FROM user WHERE id > {{ bind $.id }};
+	ListUsers(ctx context.Context, id int64) ([]*User, error)
+
+	// DeleteUsers exec const
+	// DELETE FROM user;
+	DeleteUsers(ctx context.Context) error
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_dialect
+type SuccessDialect interface {
+	WithTx(ctx context.Context, f func(SuccessDialect) error) error
+
+	// Upsert exec
+	// INSERT INTO user (id, username) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET username = excluded.username;
+	// -- dialect: mysql
+	// INSERT INTO user (id, username) VALUES (?, ?) ON DUPLICATE KEY UPDATE username = VALUES(username);
+	Upsert(ctx context.Context, id int64, username string) error
+
+	// First query one
+	// -- dialect: sqlserver
+	// SELECT TOP 1 * FROM user ORDER BY id;
+	// -- dialect: default
+	// SELECT * FROM user ORDER BY id LIMIT 1;
+	First(ctx context.Context) (*User, error)
+
+	// ListUsers query many bind
+	// -- dialect: sqlite, mysql
+	// SELECT * FROM user WHERE id > {{ bind $.id }};
+	ListUsers(ctx context.Context, id int64) ([]*User, error)
+
+	// GetUser query one constbind
+	// -- dialect: postgres
+	// SELECT * FROM users WHERE id = ${id};
+	// -- dialect: default
+	// SELECT * FROM user WHERE id = ${id};
+	GetUser(ctx context.Context, id int64) (*User, error)
+
+	// DeleteUsers exec const
+	// -- dialect: postgres
+	// TRUNCATE users;
+	// -- dialect: default
+	// DELETE FROM user;
+	DeleteUsers(ctx context.Context) error
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_dialect_duplicate
+type FailDialectDuplicate interface {
+	// First query one
+	// -- dialect: mysql
+	// SELECT * FROM user ORDER BY id LIMIT 1;
+	// -- dialect: postgres, mysql
+	// SELECT * FROM user ORDER BY id LIMIT 1;
+	First(ctx context.Context) (*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_builder
+type SuccessBuilder interface {
+	WithTx(ctx context.Context, f func(SuccessBuilder) error) error
+
+	// FindUsers query many builder
+	FindUsers(ctx context.Context, q query.Builder) ([]*User, error)
+
+	// CountUsers query one builder
+	CountUsers(q *query.SelectBuilder) (int64, error)
+
+	// First query one
+	// SELECT * FROM user ORDER BY id LIMIT 1;
+	First(ctx context.Context) (*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_builder_bind
+type FailBuilderBind interface {
+	// FindUsers query many builder bind
+	FindUsers(ctx context.Context, q query.Builder) ([]*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_builder_args
+type FailBuilderArgs interface {
+	// FindUsers query many builder
+	FindUsers(ctx context.Context, q query.Builder, limit int) ([]*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_page
+type SuccessPage interface {
+	WithTx(ctx context.Context, f func(SuccessPage) error) error
+
+	// ListUsers query many PAGE=keyset(id)
+	// SELECT * FROM user WHERE username LIKE ?;
+	ListUsers(ctx context.Context, pattern string, cursor string, size int) ([]*User, string, error)
+
+	// ListUsersBind query many bind PAGE=keyset(created_at desc, id desc)
+	// SELECT * FROM user WHERE id IN ({{ bind $.ids }});
+	ListUsersBind(ctx context.Context, ids []int64, cursor string, size int) ([]User, string, error)
+
+	// ListUsersConstBind query many constbind PAGE=keyset(id)
+	// SELECT * FROM user WHERE username = ${name};
+	ListUsersConstBind(name string, cursor string, size int) ([]*User, string, error)
+
+	// ListUsersBuilder query many builder PAGE=keyset(id)
+	ListUsersBuilder(ctx context.Context, q query.Builder, cursor string, size int) ([]*User, string, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_page_returns
+type FailPageReturns interface {
+	// ListUsers query many PAGE=keyset(id)
+	// SELECT * FROM user;
+	ListUsers(ctx context.Context, cursor string, size int) ([]*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_page_statements
+type FailPageStatements interface {
+	// ListUsers query many PAGE=keyset(id)
+	// SELECT * FROM user WHERE name = ';';
+	// SELECT * FROM user;
+	ListUsers(ctx context.Context, cursor string, size int) ([]*User, string, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_group
+type SuccessGroup interface {
+	// UsersWithProjects query many GROUP=id
+	// SELECT user.id, user.name, project.id AS "project.id", project.name AS "project.name"
+	// FROM user LEFT JOIN project ON project.user_id = user.id;
+	UsersWithProjects(ctx context.Context) ([]*User, error)
+
+	// UsersByName query bind GROUP=id,name
+	// SELECT user.id, user.name, project.id AS "project.id"
+	// FROM user LEFT JOIN project ON project.user_id = user.id WHERE user.name = {{ bind $.name }};
+	UsersByName(name string) ([]User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_group_one
+type FailGroupOne interface {
+	// UserWithProjects query one GROUP=id
+	// SELECT user.id, project.id AS "project.id" FROM user LEFT JOIN project ON project.user_id = user.id;
+	UserWithProjects(ctx context.Context) (*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_timeout
+type SuccessTimeout interface {
+	// GetUser query one TIMEOUT=500ms
+	// SELECT * FROM user WHERE id = ?;
+	GetUser(id int64) (*User, error)
+
+	// DeleteUser exec
+	// DELETE FROM user WHERE id = ?;
+	DeleteUser(ctx context.Context, id int64) (sql.Result, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_attempt_timeout
+type FailAttemptTimeout interface {
+	// GetUser query one ATTEMPT_TIMEOUT=500ms
+	// SELECT * FROM user WHERE id = ?;
+	GetUser(ctx context.Context, id int64) (*User, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_context_tx
+type SuccessContextTx interface {
+	WithTx(ctx context.Context, f func(SuccessContextTx) error) error
+
+	// GetUser query one
+	// SELECT * FROM user WHERE id = ?;
+	GetUser(ctx context.Context, id int64) (*User, error)
+
+	// DeleteUser exec
+	// DELETE FROM user WHERE id = ?;
+	DeleteUser(id int64) (sql.Result, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_cache
+type SuccessCache interface {
+	WithTx(ctx context.Context, f func(SuccessCache) error) error
+
+	// GetUser query one
+	// SELECT * FROM user WHERE id = ?;
+	GetUser(ctx context.Context, id int64) (*User, error)
+
+	// ListUsers query many
+	// SELECT * FROM user WHERE name = ? LIMIT ?;
+	ListUsers(name string, limit int) ([]*User, error)
+
+	// UpdateUser exec INVALIDATE=GetUser,ListUsers,SuccessContextTx.GetUser
+	// UPDATE user SET name = ? WHERE id = ?;
+	UpdateUser(ctx context.Context, name string, id int64) (sql.Result, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/fail_invalidate
+type FailInvalidate interface {
+	// GetUser query one
+	// SELECT * FROM user WHERE id = ?;
+	GetUser(ctx context.Context, id int64) (*User, error)
+
+	// UpdateUser exec INVALIDATE=GetUsers
+	// UPDATE user SET name = ? WHERE id = ?;
+	UpdateUser(ctx context.Context, name string, id int64) (sql.Result, error)
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_generic
+type SuccessGeneric[T any, ID comparable] interface {
+	WithTx(ctx context.Context, f func(SuccessGeneric[T, ID]) error) error
+
+	// Get query one
+	// SELECT * FROM {{ $.table }} WHERE id = ?;
+	Get(ctx context.Context, id ID) (*T, error)
+
+	// List query many bind
+	// SELECT * FROM {{ $.table }} WHERE id IN ({{ bind $.ids }});
+	List(ctx context.Context, ids []ID) ([]T, error)
+
+	// Delete exec const
+	// DELETE FROM user WHERE id = ?;
+	Delete(ctx context.Context, id ID) error
+}
+
+//go:generate defc [mode] [output] [features...] TestBuildSqlx/success_batch_callback
+type SuccessBatchCallback interface {
+	// ListProjects query many
+	// SELECT * FROM project WHERE user_id = ?;
+	ListProjects(ctx context.Context, userID int64) ([]*Project, error)
+
+	// ListProjectValues query many
+	// SELECT * FROM project WHERE user_id = ?;
+	ListProjectValues(userID int64) ([]ProjectValue, error)
+}
+
+type Project struct {
+	ID     int64
+	UserID int64
+}
+
+func (*Project) BatchCallback(ctx context.Context, query SuccessBatchCallback, projects []*Project) error {
+	return nil
+}
+
+type ProjectValue Project
+
+func (*ProjectValue) BatchCallback(ctx context.Context, query SuccessBatchCallback, projects []ProjectValue) error {
+	return nil
+}
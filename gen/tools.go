@@ -6,14 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/importer"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func assert(expr bool, msg string) {
@@ -38,6 +42,7 @@ var (
 	split      = strings.Split
 	concat     = strings.Join
 	toUpper    = strings.ToUpper
+	toLower    = strings.ToLower
 	index      = strings.Index
 	cut        = strings.Cut
 	contains   = strings.Contains
@@ -80,6 +85,16 @@ func deselect(node ast.Node) ast.Node {
 	return node
 }
 
+func unindex(node ast.Expr) ast.Expr {
+	switch expr := node.(type) {
+	case *ast.IndexExpr:
+		return expr.X
+	case *ast.IndexListExpr:
+		return expr.X
+	}
+	return node
+}
+
 func isPointer(node ast.Node) bool {
 	_, ok := node.(*ast.StarExpr)
 	return ok
@@ -100,6 +115,36 @@ func isSlice(node ast.Node) bool {
 	return typ.Len == nil && !eltIsByte
 }
 
+// elem returns the element type of a slice type.
+func elem(node ast.Node) ast.Expr {
+	if typ, ok := node.(*ast.ArrayType); ok {
+		return typ.Elt
+	}
+	return nil
+}
+
+// durationExpr returns the Go expression of duration in the largest unit dividing it, such as `500 * time.Millisecond`.
+func durationExpr(duration time.Duration) string {
+	for _, unit := range []struct {
+		duration time.Duration
+		name     string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	} {
+		if duration%unit.duration == 0 {
+			if duration == unit.duration {
+				return unit.name
+			}
+			return sprintf("%d * %s", duration/unit.duration, unit.name)
+		}
+	}
+	return sprintf("%d * time.Nanosecond", duration)
+}
+
 func isChan(node ast.Node) bool {
 	_, ok := node.(*ast.ChanType)
 	return ok
@@ -429,3 +474,50 @@ func maybeRpcDecl(iface *ast.InterfaceType) bool {
 	}
 	return true
 }
+
+// checkFile type-checks a schema file on its own, ignoring errors, so that the types it imports or declares can be
+// inspected. Types declared in other files of its package are invalid in the returned info.
+func checkFile(fset *token.FileSet, f *ast.File) (*types.Package, *types.Info) {
+	exports := exportFiles(filepath.Dir(fset.Position(f.Pos()).Filename), f.Imports)
+	var (
+		info = &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+		conf = types.Config{
+			Importer: importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+				if export := exports[path]; export != "" {
+					return os.Open(export)
+				}
+				return nil, fmt.Errorf("no export data found for package %s", quote(path))
+			}),
+			Error: func(error) {},
+		}
+	)
+	pkg, _ := conf.Check(f.Name.Name, fset, []*ast.File{f}, info)
+	return pkg, info
+}
+
+// exportFiles returns the files holding the export data of the packages imported by a file, keyed by import path,
+// `go list` builds them in the build cache if needed. Packages which fail to build are left out.
+func exportFiles(dir string, imports []*ast.ImportSpec) map[string]string {
+	args := []string{"list", "-e", "-export", "-f", "{{ .ImportPath }} {{ .Export }}"}
+	for _, imp := range imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err == nil && path != "C" && path != "unsafe" {
+			args = append(args, path)
+		}
+	}
+	exports := make(map[string]string, len(imports))
+	if len(args) == 5 {
+		return exports
+	}
+	command := exec.Command("go", args...)
+	command.Dir = dir
+	output, err := command.Output()
+	if err != nil {
+		return exports
+	}
+	for _, line := range split(trimSpace(string(output)), "\n") {
+		if path, export, ok := cut(line, " "); ok {
+			exports[path] = export
+		}
+	}
+	return exports
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	goimport "golang.org/x/tools/imports"
@@ -40,6 +41,7 @@ var (
 		gen.FeatureApiGzip,
 		gen.FeatureApiRetry,
 		gen.FeatureApiGetBody,
+		gen.FeatureApiConditional,
 		gen.FeatureSqlxIn,
 		gen.FeatureSqlxLog,
 		gen.FeatureSqlxRebind,
@@ -47,6 +49,11 @@ var (
 		gen.FeatureSqlxFuture,
 		gen.FeatureSqlxCallback,
 		gen.FeatureSqlxAnyCallback,
+		gen.FeatureSqlxExplain,
+		gen.FeatureSqlxInterpolate,
+		gen.FeatureSqlxOverride,
+		gen.FeatureSqlxContextTx,
+		gen.FeatureSqlxCache,
 		gen.FeatureRpcNoRt,
 	}
 )
@@ -70,6 +77,7 @@ var (
 	funcs             []string
 	targetType        string
 	template          string
+	timeout           time.Duration
 )
 
 var (
@@ -179,7 +187,8 @@ defc provides the following three scenarios of code generation features:
 				WithPkg(os.Getenv(EnvGoPackage)).
 				WithPwd(pwd).
 				WithFile(file, doc).
-				WithPos(pos)
+				WithPos(pos).
+				WithTimeout(timeout)
 
 			var buffer bytes.Buffer
 			if err = builder.Build(&buffer); err != nil {
@@ -205,7 +214,15 @@ generated code file's name. This allows you to generate the corresponding code b
 any additional flags. 
 
 If your Go file contains multiple interface types that meet the criteria, you can manually specify which interface 
-type defc should handle using the '--type/-T' parameter to avoid generating incorrect code.`,
+type defc should handle using the '--type/-T' parameter to avoid generating incorrect code.
+
+Specify '--mode=fake' to generate a fake implementation of the detected interface for tests instead, its default 
+output file uses a .fake.go suffix.
+
+The generate command also accepts .sql files whose queries are annotated in the style of sqlc, such as 
+'-- name: GetUser :one', along with '-- param: id int64' and '-- result: *User' annotations. defc generates both the 
+schema interface and its sqlx implementation into a file named after the .sql file with a .go suffix, e.g. 
+queries.sql.go.`,
 		Args:          cobra.MaximumNArgs(1),
 		SilenceUsage:  true,
 		SilenceErrors: true,
@@ -218,27 +235,35 @@ type defc should handle using the '--type/-T' parameter to avoid generating inco
 			} else {
 				return fmt.Errorf("unable to retrieve schema file from the $GOFILE environment variable or positional arguments")
 			}
-			if ext := filepath.Ext(file); ext == ".go" {
-				var (
-					pwd = os.Getenv(EnvPWD)
-					doc []byte
-					pos int
-					pkg string
-					mod gen.Mode
-					out = output
-				)
-				if pwd == "" {
-					pwd, err = os.Getwd()
-					if err != nil {
-						return fmt.Errorf("get current working directory: %w", err)
-					}
-				}
-				if !filepath.IsAbs(file) {
-					file = filepath.Join(pwd, file)
-				}
-				if doc, err = os.ReadFile(file); err != nil {
-					return fmt.Errorf("os.ReadFile(%q): %w", file, err)
+			ext := filepath.Ext(file)
+			if ext != ".go" && ext != ".sql" {
+				return fmt.Errorf("generate command only supports .go and .sql files, got %q", ext)
+			}
+			var (
+				pwd = os.Getenv(EnvPWD)
+				doc []byte
+				pos int
+				pkg string
+				mod gen.Mode
+				out = output
+			)
+			if pwd == "" {
+				pwd, err = os.Getwd()
+				if err != nil {
+					return fmt.Errorf("get current working directory: %w", err)
 				}
+			}
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(pwd, file)
+			}
+			if doc, err = os.ReadFile(file); err != nil {
+				return fmt.Errorf("os.ReadFile(%q): %w", file, err)
+			}
+			if ext == ".sql" {
+				// The schema interface of a .sql file is generated together with its implementation,
+				// whose package is specified by $GOPACKAGE or the `-- package:` annotation of the file.
+				mod = gen.ModeSqlx
+			} else {
 				var declNotFoundErr error
 				pkg, mod, pos, declNotFoundErr = gen.DetectTargetDecl(file, doc, targetType)
 				specifyManually := len(args) > 0 || targetType != ""
@@ -251,66 +276,74 @@ type defc should handle using the '--type/-T' parameter to avoid generating inco
 						return fmt.Errorf("gen.DetectTargetDecl: %w", declNotFoundErr)
 					}
 				}
-				if goPackage := os.Getenv(EnvGoPackage); goPackage != "" {
-					pkg = goPackage
+			}
+			if goPackage := os.Getenv(EnvGoPackage); goPackage != "" {
+				pkg = goPackage
+			}
+			if mode != "" {
+				if mod = modeMap[mode]; !mod.IsValid() {
+					return fmt.Errorf("invalid mode %q, available modes are: [%s]", mode, printStrings(validModes))
 				}
-				if mode != "" {
-					if mod = modeMap[mode]; !mod.IsValid() {
-						return fmt.Errorf("invalid mode %q, available modes are: [%s]", mode, printStrings(validModes))
-					}
+				if ext == ".sql" && mod != gen.ModeSqlx {
+					return fmt.Errorf("mode=%s is not supported for .sql files, only mode=%s is available", mod, gen.ModeSqlx)
 				}
-				if out == "" {
+			}
+			if out == "" {
+				if ext == ".sql" {
+					out = file + ".go"
+				} else if mod == gen.ModeFake {
+					out = strings.TrimSuffix(file, ext) + ".fake" + ext
+				} else {
 					out = strings.TrimSuffix(file, ext) + ".gen" + ext
 				}
-				mode, output = mod.String(), out
-				if err = checkFlags(); err != nil {
-					return err
+			}
+			mode, output = mod.String(), out
+			if err = checkFlags(); err != nil {
+				return err
+			}
+			if template != "" {
+				if mod == gen.ModeApi || mod == gen.ModeFake {
+					return fmt.Errorf("the --template/-t option is not supported in the current mode=%s scenario", mod)
 				}
-				if template != "" {
-					if mod == gen.ModeApi {
-						return errors.New("the --template/-t option is not supported in the current mode=api scenario")
+				// The --template option supports two types of parameters. The first type is the path of a template
+				// file, the program will read the content string of the file and generate a template. The second
+				// type starts with a colon followed by an expression string. The program will remove the colon and
+				// use the expression after the colon as the template string, generating a template based on the
+				// value of that expression.
+				if strings.HasPrefix(template, ":") {
+					template = template[1:]
+					if template == "" {
+						return errors.New("invalid empty template")
 					}
-					// The --template option supports two types of parameters. The first type is the path of a template
-					// file, the program will read the content string of the file and generate a template. The second
-					// type starts with a colon followed by an expression string. The program will remove the colon and
-					// use the expression after the colon as the template string, generating a template based on the
-					// value of that expression.
-					if strings.HasPrefix(template, ":") {
-						template = template[1:]
-						if template == "" {
-							return errors.New("invalid empty template")
-						}
-					} else {
-						if !filepath.IsAbs(template) {
-							template = filepath.Join(pwd, template)
-						}
-						templateBytes, err := os.ReadFile(template)
-						if err != nil {
-							return fmt.Errorf("os.ReadFile(%q): %w", template, err)
-						}
-						template = strconv.Quote(string(templateBytes))
+				} else {
+					if !filepath.IsAbs(template) {
+						template = filepath.Join(pwd, template)
 					}
+					templateBytes, err := os.ReadFile(template)
+					if err != nil {
+						return fmt.Errorf("os.ReadFile(%q): %w", template, err)
+					}
+					template = strconv.Quote(string(templateBytes))
 				}
-				builder := gen.NewCliBuilder(mod).
-					WithFeats(features).
-					WithImports(imports).
-					WithFuncs(funcs).
-					WithPkg(pkg).
-					WithPwd(pwd).
-					WithFile(file, doc).
-					WithPos(pos).
-					WithTemplate(template)
-				var buffer bytes.Buffer
-				if err = builder.Build(&buffer); err != nil {
-					return err
-				}
-				if !filepath.IsAbs(output) {
-					output = filepath.Join(pwd, output)
-				}
-				return save(output, buffer.Bytes())
-			} else {
-				return fmt.Errorf("generate command only supports .go files, got %q", ext)
 			}
+			builder := gen.NewCliBuilder(mod).
+				WithFeats(features).
+				WithImports(imports).
+				WithFuncs(funcs).
+				WithPkg(pkg).
+				WithPwd(pwd).
+				WithFile(file, doc).
+				WithPos(pos).
+				WithTemplate(template).
+				WithTimeout(timeout)
+			var buffer bytes.Buffer
+			if err = builder.Build(&buffer); err != nil {
+				return err
+			}
+			if !filepath.IsAbs(output) {
+				output = filepath.Join(pwd, output)
+			}
+			return save(output, buffer.Bytes())
 		},
 	}
 )
@@ -350,6 +383,9 @@ func checkFlags() (err error) {
 	if err = checkFeatures(features); err != nil {
 		return err
 	}
+	if timeout < 0 {
+		return fmt.Errorf("`--timeout` expects a non-negative duration, got %s", timeout)
+	}
 	return nil
 }
 
@@ -397,6 +433,7 @@ func init() {
 	flags.BoolVar(&disableAutoImport, "disable-auto-import", false, "disable auto import and import packages manually by '--import' option")
 	flags.StringArrayVar(&funcs, "func", nil, "additional funcs")
 	flags.StringArrayVar(&funcs, "function", nil, "additional funcs")
+	flags.DurationVar(&timeout, "timeout", 0, "default timeout of methods without the TIMEOUT option")
 
 	// [2024-04-07]
 	// Since we use the `checkFlags` function to validate required parameters,
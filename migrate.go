@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/x5iu/defc/runtime/migrate"
+)
+
+const (
+	EnvMigrateOptions = "DEFC_MIGRATE_OPTIONS"
+	EnvDatabaseURL    = "DATABASE_URL"
+)
+
+var (
+	migrateOptions      migrate.Options
+	migrateDriverImport string
+)
+
+// knownDriverImports are the packages imported for the most common driver names when --driver-import is omitted.
+var knownDriverImports = map[string]string{
+	"sqlite3":   "github.com/mattn/go-sqlite3",
+	"postgres":  "github.com/lib/pq",
+	"pgx":       "github.com/jackc/pgx/v5/stdlib",
+	"mysql":     "github.com/go-sql-driver/mysql",
+	"sqlserver": "github.com/microsoft/go-mssqldb",
+	"godror":    "github.com/godror/godror",
+}
+
+var (
+	migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply versioned SQL migrations",
+		Long: `The migrate command applies the migrations of a directory, which are files named NNNN_name.up.sql and
+NNNN_name.down.sql, and records them in a version table together with the checksum of their up file.
+
+Since defc itself does not link any database driver, the up, down and status subcommands build a small program importing
+the driver with 'go build' in the current module, which should therefore require both the driver package and
+github.com/x5iu/defc. The driver package is guessed from the driver name for common drivers, or specified with
+'--driver-import'.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	migrateUp = &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(append([]string{"up"}, args...))
+		},
+	}
+
+	migrateDown = &cobra.Command{
+		Use:   "down [N]",
+		Short: "Roll back the latest N applied migrations (1 by default)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(append([]string{"down"}, args...))
+		},
+	}
+
+	migrateStatus = &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of all migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(append([]string{"status"}, args...))
+		},
+	}
+
+	migrateCreate = &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create the up and down files of a new migration",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			up, down, err := migrate.Create(migrateOptions.Dir, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "created %s\ncreated %s\n", up, down)
+			return nil
+		},
+	}
+)
+
+func runMigrate(args []string) error {
+	if migrateOptions.Driver == "" {
+		return errors.New("`--driver` required")
+	}
+	if migrateOptions.DSN == "" {
+		migrateOptions.DSN = os.Getenv(EnvDatabaseURL)
+	}
+	if !filepath.IsAbs(migrateOptions.Dir) {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get current working directory: %w", err)
+		}
+		migrateOptions.Dir = filepath.Join(pwd, migrateOptions.Dir)
+	}
+	for _, driver := range sql.Drivers() {
+		if driver == migrateOptions.Driver {
+			return migrate.Command(context.Background(), os.Stdout, migrateOptions, args)
+		}
+	}
+	driverImport := migrateDriverImport
+	if driverImport == "" {
+		if driverImport = knownDriverImports[migrateOptions.Driver]; driverImport == "" {
+			return fmt.Errorf("unknown driver %q, specify the package of the driver with `--driver-import`", migrateOptions.Driver)
+		}
+	}
+	return runMigrateProgram(driverImport, args)
+}
+
+const migrateProgram = `package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/x5iu/defc/runtime/migrate"
+
+	_ %q
+)
+
+func main() {
+	var opts migrate.Options
+	if err := json.Unmarshal([]byte(os.Getenv(%q)), &opts); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if err := migrate.Command(context.Background(), os.Stdout, opts, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+`
+
+// runMigrateProgram builds and runs a program calling migrate.Command with driverImport imported, options are passed
+// through the environment rather than the source of the program, so that the DSN is never written to disk.
+func runMigrateProgram(driverImport string, args []string) error {
+	dir, err := os.MkdirTemp("", "defc-migrate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	var (
+		program    = filepath.Join(dir, "main.go")
+		executable = filepath.Join(dir, "migrate")
+	)
+	if err = os.WriteFile(program, []byte(fmt.Sprintf(migrateProgram, driverImport, EnvMigrateOptions)), 0644); err != nil {
+		return err
+	}
+	build := exec.Command("go", "build", "-o", executable, program)
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err = build.Run(); err != nil {
+		return fmt.Errorf("go build %s: %w", driverImport, err)
+	}
+	opts, err := json.Marshal(migrateOptions)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), EnvMigrateOptions+"="+string(opts))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// The program has already reported its error.
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
+
+func init() {
+	defc.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateUp, migrateDown, migrateStatus, migrateCreate)
+
+	flags := migrateCmd.PersistentFlags()
+	flags.StringVar(&migrateOptions.Driver, "driver", "", "database driver name, e.g. sqlite3, postgres or mysql")
+	flags.StringVar(&migrateOptions.DSN, "dsn", "", "data source name, defaults to $"+EnvDatabaseURL)
+	flags.StringVar(&migrateOptions.Dir, "dir", "migrations", "directory of migration files")
+	flags.StringVar(&migrateOptions.Table, "table", migrate.DefaultTable, "name of the version table")
+	flags.StringVar(&migrateDriverImport, "driver-import", "", "package of the database driver, e.g. github.com/lib/pq")
+	migrateCmd.MarkPersistentFlagDirname("dir")
+}
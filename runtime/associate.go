@@ -0,0 +1,52 @@
+package defc
+
+// Keys returns the distinct keys of items in their first-seen order, such as the IDs of parents whose children are
+// then loaded with a single "WHERE parent_id IN (...)" query, usually in a BatchCallback.
+func Keys[T any, K comparable](items []T, key func(T) K) []K {
+	var (
+		keys = make([]K, 0, len(items))
+		seen = make(map[K]struct{}, len(items))
+	)
+	for _, item := range items {
+		k := key(item)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Associate groups children by their foreign key and assigns each group to the parents with the same key, in the
+// order of children. Parents without children are assigned nil, and children without parents are dropped.
+// Parents are taken by pointer so that assign reaches the elements of the caller's slice; for results of []T,
+// pass pointers to its elements instead:
+//
+//	func (*User) BatchCallback(ctx context.Context, q UserQuery, users []*User) error {
+//		projects, err := q.GetProjectsByUserIDs(ctx, defc.Keys(users, func(u *User) int64 { return u.ID }))
+//		if err != nil {
+//			return err
+//		}
+//		defc.Associate(users, projects,
+//			func(u *User) int64 { return u.ID },
+//			func(p *Project) int64 { return p.UserID },
+//			func(u *User, projects []*Project) { u.Projects = projects })
+//		return nil
+//	}
+func Associate[P any, C any, K comparable](
+	parents []*P,
+	children []C,
+	parentKey func(*P) K,
+	childKey func(C) K,
+	assign func(*P, []C),
+) {
+	groups := make(map[K][]C, len(parents))
+	for _, child := range children {
+		k := childKey(child)
+		groups[k] = append(groups[k], child)
+	}
+	for _, parent := range parents {
+		assign(parent, groups[parentKey(parent)])
+	}
+}
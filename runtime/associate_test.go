@@ -0,0 +1,67 @@
+package defc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssociate(t *testing.T) {
+	type Project struct {
+		ID     int
+		UserID int
+	}
+	type User struct {
+		ID       int
+		Projects []*Project
+	}
+	var (
+		users    = []*User{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 1}}
+		projects = []*Project{{ID: 10, UserID: 2}, {ID: 11, UserID: 1}, {ID: 12, UserID: 2}, {ID: 13, UserID: 4}}
+	)
+	keys := Keys(users, func(u *User) int { return u.ID })
+	if expect := []int{1, 2, 3}; !reflect.DeepEqual(keys, expect) {
+		t.Errorf("keys: %v != %v", keys, expect)
+		return
+	}
+	Associate(users, projects,
+		func(u *User) int { return u.ID },
+		func(p *Project) int { return p.UserID },
+		func(u *User, projects []*Project) { u.Projects = projects })
+	for i, expect := range [][]int{{11}, {10, 12}, nil, {11}} {
+		var ids []int
+		for _, project := range users[i].Projects {
+			ids = append(ids, project.ID)
+		}
+		if !reflect.DeepEqual(ids, expect) {
+			t.Errorf("associate: user %d has projects %v != %v", users[i].ID, ids, expect)
+		}
+	}
+}
+
+func TestAssociateValues(t *testing.T) {
+	type Project struct {
+		ID     int
+		UserID int
+	}
+	type User struct {
+		ID       int
+		Projects []*Project
+	}
+	var (
+		users    = []User{{ID: 1}, {ID: 2}}
+		projects = []*Project{{ID: 10, UserID: 2}, {ID: 11, UserID: 1}}
+		parents  = make([]*User, len(users))
+	)
+	for i := range users {
+		parents[i] = &users[i]
+	}
+	Associate(parents, projects,
+		func(u *User) int { return u.ID },
+		func(p *Project) int { return p.UserID },
+		func(u *User, projects []*Project) { u.Projects = projects })
+	for i, expect := range []int{11, 10} {
+		if len(users[i].Projects) != 1 || users[i].Projects[0].ID != expect {
+			t.Errorf("associate: user %d has projects %v, expects [%d]", users[i].ID, users[i].Projects, expect)
+		}
+	}
+}
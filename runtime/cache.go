@@ -0,0 +1,31 @@
+package defc
+
+import (
+	"time"
+
+	"github.com/x5iu/defc/runtime/cache"
+)
+
+// Cache is an in-memory cache of method results, whose entries expire after a TTL and the least recently used of
+// which are evicted beyond a capacity. It implements the hooks of the sqlx/cache feature (and of the api/cache
+// feature), so that it can be embedded into the core given to generated schemas alongside the *sqlx.DB:
+//
+//	type Core struct {
+//		*sqlx.DB
+//		*defc.Cache
+//	}
+//
+//	query := NewQueryFromCore(&Core{DB: db, Cache: defc.NewCache(1024, time.Minute)})
+//
+// Cached values are shared by all callers, which should not modify them. See the cache package for per-method TTLs.
+type Cache = cache.Cache
+
+// NewCache returns a Cache of at most capacity entries living ttl each, a non-positive capacity or ttl is unlimited.
+func NewCache(capacity int, ttl time.Duration) *Cache {
+	c, err := cache.New(cache.Options{Size: capacity, TTL: ttl})
+	if err != nil {
+		// the size given to the LRU is always positive
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,274 @@
+// Package cache provides an in-memory cache of method results, which implements the hooks of the api/cache and
+// sqlx/cache features, so that it can be embedded into the type returned by the `Options()` method of api schemas, or
+// into the core given to sqlx schemas:
+//
+//	type Options struct {
+//		*cache.Cache
+//	}
+//
+//	c, err := cache.New(cache.Options{Size: 1024, TTL: time.Minute, MethodTTL: map[string]time.Duration{
+//		"GetUser": 10 * time.Second,
+//	}})
+//
+// Entries expire after the TTL of their method and the least recently used ones are evicted beyond the size of the
+// cache. Concurrent misses of the same method and arguments are deduplicated: the first caller gets nil and is expected
+// to call SetCache (or ReleaseCache if it fails), while the others wait for it and get its values. Values set for a
+// miss which happened before an InvalidateCache of the method are dropped, since they may have been read before the
+// invalidated changes.
+package cache
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// Options configures a Cache.
+type Options struct {
+	// Size is the maximum number of entries, a non-positive Size is unbounded.
+	Size int
+
+	// TTL is how long entries live, a non-positive TTL never expires entries.
+	TTL time.Duration
+
+	// MethodTTL overrides TTL for some methods, which are named as by the generated code, that is `Method` in api mode
+	// and `Schema.Method` in sqlx mode.
+	MethodTTL map[string]time.Duration
+
+	// Wait bounds how long concurrent misses wait for the first one to complete, a zero Wait waits until it calls
+	// SetCache or ReleaseCache, and a negative Wait disables the deduplication of misses.
+	Wait time.Duration
+}
+
+// Cache is a TTL and LRU cache of method results, which is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	opts    Options
+	lru     *simplelru.LRU[string, *entry]
+	methods map[string]map[string]struct{}
+	flights map[string]chan struct{}
+
+	// generations counts the invalidations of each method, and misses the pending misses of each key together with
+	// the oldest generation they were taken at, so that SetCache drops values which may predate an invalidation.
+	generations map[string]uint64
+	misses      map[string]*miss
+}
+
+type miss struct {
+	generation uint64
+	pending    int
+}
+
+type entry struct {
+	method  string
+	values  []any
+	expires time.Time
+}
+
+// New returns an empty Cache configured by opts.
+func New(opts Options) (*Cache, error) {
+	c := &Cache{
+		opts:    opts,
+		methods: make(map[string]map[string]struct{}),
+		flights: make(map[string]chan struct{}),
+
+		generations: make(map[string]uint64),
+		misses:      make(map[string]*miss),
+	}
+	size := opts.Size
+	if size <= 0 {
+		size = math.MaxInt
+	}
+	var err error
+	if c.lru, err = simplelru.NewLRU[string, *entry](size, c.evicted); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// evicted is called by the LRU whenever an entry is removed, either evicted or expired.
+func (c *Cache) evicted(key string, entry *entry) {
+	if keys := c.methods[entry.method]; keys != nil {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.methods, entry.method)
+		}
+	}
+}
+
+// GetCache returns the values cached for method called with args, or nil if there is none, in which case the caller
+// should call SetCache or ReleaseCache once it is done. Arguments that cannot be hashed by Key make values uncacheable.
+func (c *Cache) GetCache(method string, args ...any) []any {
+	key, ok := entryKey(method, args)
+	if !ok {
+		return nil
+	}
+	var timeout <-chan time.Time
+	if c.opts.Wait > 0 {
+		timer := time.NewTimer(c.opts.Wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		c.mu.Lock()
+		if entry, ok := c.lru.Get(key); ok {
+			if entry.expires.IsZero() || time.Now().Before(entry.expires) {
+				c.mu.Unlock()
+				return entry.values
+			}
+			c.lru.Remove(key)
+		}
+		if c.opts.Wait < 0 {
+			c.miss(method, key)
+			c.mu.Unlock()
+			return nil
+		}
+		flight, ok := c.flights[key]
+		if !ok {
+			c.flights[key] = make(chan struct{})
+			c.miss(method, key)
+			c.mu.Unlock()
+			return nil
+		}
+		c.mu.Unlock()
+		select {
+		case <-flight:
+			// values have been set, or the first caller failed and this caller may take over
+		case <-timeout:
+			c.mu.Lock()
+			c.miss(method, key)
+			c.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// miss records a miss of key, which is settled by SetCache or ReleaseCache.
+func (c *Cache) miss(method string, key string) {
+	if m, ok := c.misses[key]; ok {
+		m.pending++
+		return
+	}
+	c.misses[key] = &miss{generation: c.generations[method], pending: 1}
+}
+
+// settle settles a miss of key and reports whether method has not been invalidated since the oldest pending miss of
+// key, or since ever if there is none.
+func (c *Cache) settle(method string, key string) bool {
+	m, ok := c.misses[key]
+	if !ok {
+		return true
+	}
+	if m.pending--; m.pending <= 0 {
+		delete(c.misses, key)
+	}
+	return m.generation == c.generations[method]
+}
+
+// SetCache caches values as the results of method called with args, and wakes up the callers waiting for them. Values
+// are dropped if method has been invalidated since GetCache missed them.
+func (c *Cache) SetCache(method string, args []any, values ...any) {
+	key, ok := entryKey(method, args)
+	if !ok {
+		return
+	}
+	ttl := c.opts.TTL
+	if methodTTL, ok := c.opts.MethodTTL[method]; ok {
+		ttl = methodTTL
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.settle(method, key) {
+		c.add(key, &entry{method: method, values: values, expires: expires})
+	}
+	c.land(key)
+}
+
+func (c *Cache) add(key string, entry *entry) {
+	c.lru.Add(key, entry)
+	keys, ok := c.methods[entry.method]
+	if !ok {
+		keys = make(map[string]struct{})
+		c.methods[entry.method] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// ReleaseCache wakes up the callers waiting for the values of method called with args without caching any, it is a
+// no-op if SetCache has been called.
+func (c *Cache) ReleaseCache(method string, args ...any) {
+	key, ok := entryKey(method, args)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle(method, key)
+	c.land(key)
+}
+
+func (c *Cache) land(key string) {
+	if flight, ok := c.flights[key]; ok {
+		close(flight)
+		delete(c.flights, key)
+	}
+}
+
+// GetConditional returns the header and the body of the response stored for method and url by SetConditional, which
+// implements the hooks of the api/conditional feature.
+func (c *Cache) GetConditional(method string, url string) (http.Header, []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.lru.Get(conditionalKey(method, url)); ok {
+		return entry.values[0].(http.Header), entry.values[1].([]byte)
+	}
+	return nil, nil
+}
+
+// SetConditional stores the header and the body of the response of method for url, which never expire since they are
+// revalidated by each request, but are evicted and invalidated as other entries.
+func (c *Cache) SetConditional(method string, url string, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(conditionalKey(method, url), &entry{method: method, values: []any{header, body}})
+}
+
+// InvalidateCache removes the values cached for methods, whatever their arguments, and the values being computed for
+// them by callers which missed them before.
+func (c *Cache) InvalidateCache(methods ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, method := range methods {
+		c.generations[method]++
+		for key := range c.methods[method] {
+			c.lru.Remove(key)
+		}
+	}
+}
+
+// Len returns the number of entries, including expired ones which have not been removed yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func entryKey(method string, args []any) (string, bool) {
+	key, ok := Key(args...)
+	if !ok {
+		return "", false
+	}
+	return method + "\x00" + key, true
+}
+
+// conditionalKey never collides with keys of entryKey, whose hashes are hexadecimal.
+func conditionalKey(method string, url string) string {
+	return method + "\x00conditional\x00" + url
+}
@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c, err := New(Options{Size: 2, TTL: time.Hour, MethodTTL: map[string]time.Duration{"Short": time.Millisecond}})
+	if err != nil {
+		t.Errorf("cache: %s", err)
+		return
+	}
+	c.SetCache("Get", []any{1}, "one")
+	c.SetCache("Short", []any{1}, "short")
+	time.Sleep(2 * time.Millisecond)
+	if values := c.GetCache("Get", 1); !reflect.DeepEqual(values, []any{"one"}) {
+		t.Errorf("cache: %v != [one]", values)
+		return
+	}
+	if values := c.GetCache("Short", 1); values != nil {
+		t.Errorf("cache: expects entry expired by its method TTL, got %v", values)
+		return
+	}
+	c.ReleaseCache("Short", 1)
+	c.SetCache("List", []any{"a"}, []string{"a"})
+	c.SetCache("List", []any{"b"}, []string{"b"})
+	if values := c.GetCache("Get", 1); values != nil || c.Len() != 2 {
+		t.Errorf("cache: expects the least recently used entry to be evicted, got %v", values)
+		return
+	}
+	c.ReleaseCache("Get", 1)
+	c.InvalidateCache("List")
+	if c.Len() != 0 {
+		t.Errorf("cache: expects all entries of List to be invalidated, %d left", c.Len())
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	t.Run("stale", func(t *testing.T) {
+		c, err := New(Options{})
+		if err != nil {
+			t.Errorf("cache: %s", err)
+			return
+		}
+		if values := c.GetCache("Get", 1); values != nil {
+			t.Errorf("cache: unexpected values %v", values)
+			return
+		}
+		// the row is updated and Get invalidated while the first caller is still reading the old row
+		c.InvalidateCache("Get")
+		c.SetCache("Get", []any{1}, "stale")
+		if values := c.GetCache("Get", 1); values != nil {
+			t.Errorf("cache: expects values read before the invalidation to be dropped, got %v", values)
+			return
+		}
+		c.SetCache("Get", []any{1}, "fresh")
+		if values := c.GetCache("Get", 1); !reflect.DeepEqual(values, []any{"fresh"}) {
+			t.Errorf("cache: %v != [fresh]", values)
+			return
+		}
+		c.InvalidateCache("List")
+		if values := c.GetCache("Get", 1); !reflect.DeepEqual(values, []any{"fresh"}) {
+			t.Errorf("cache: expects values to survive the invalidation of other methods, got %v", values)
+			return
+		}
+	})
+	t.Run("concurrent_misses", func(t *testing.T) {
+		c, err := New(Options{Wait: -1})
+		if err != nil {
+			t.Errorf("cache: %s", err)
+			return
+		}
+		c.GetCache("Get", 1) // missed before the invalidation, reads the old row
+		c.InvalidateCache("Get")
+		c.GetCache("Get", 1) // missed after the invalidation, reads the new row
+		c.SetCache("Get", []any{1}, "fresh")
+		c.SetCache("Get", []any{1}, "stale")
+		if values := c.GetCache("Get", 1); values != nil {
+			t.Errorf("cache: expects values of misses overlapping an invalidation to be dropped, got %v", values)
+			return
+		}
+		c.SetCache("Get", []any{1}, "fresh")
+		if values := c.GetCache("Get", 1); !reflect.DeepEqual(values, []any{"fresh"}) {
+			t.Errorf("cache: %v != [fresh]", values)
+			return
+		}
+	})
+	t.Run("release", func(t *testing.T) {
+		c, err := New(Options{})
+		if err != nil {
+			t.Errorf("cache: %s", err)
+			return
+		}
+		c.GetCache("Get", 1)
+		c.InvalidateCache("Get")
+		c.ReleaseCache("Get", 1)
+		c.GetCache("Get", 1)
+		c.SetCache("Get", []any{1}, "fresh")
+		if values := c.GetCache("Get", 1); !reflect.DeepEqual(values, []any{"fresh"}) {
+			t.Errorf("cache: expects released misses to be settled, got %v", values)
+			return
+		}
+	})
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	c, err := New(Options{})
+	if err != nil {
+		t.Errorf("cache: %s", err)
+		return
+	}
+	var (
+		calls int32
+		wg    sync.WaitGroup
+	)
+	results := make([][]any, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if results[i] = c.GetCache("Get", 1); results[i] == nil {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(10 * time.Millisecond)
+				results[i] = []any{"one"}
+				c.SetCache("Get", []any{1}, results[i]...)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("cache: expects concurrent misses to be deduplicated, got %d calls", calls)
+		return
+	}
+	for _, values := range results {
+		if !reflect.DeepEqual(values, []any{"one"}) {
+			t.Errorf("cache: %v != [one]", values)
+			return
+		}
+	}
+
+	// the failure of the first caller lets a waiting caller take over
+	if values := c.GetCache("Fail", 1); values != nil {
+		t.Errorf("cache: unexpected values %v", values)
+		return
+	}
+	done := make(chan []any)
+	go func() { done <- c.GetCache("Fail", 1) }()
+	time.Sleep(5 * time.Millisecond)
+	c.ReleaseCache("Fail", 1)
+	if values := <-done; values != nil {
+		t.Errorf("cache: unexpected values %v", values)
+		return
+	}
+
+	c, _ = New(Options{Wait: time.Millisecond})
+	c.GetCache("Get", 1)
+	if values := c.GetCache("Get", 1); values != nil {
+		t.Errorf("cache: unexpected values %v", values)
+	}
+}
+
+func TestCacheConditional(t *testing.T) {
+	c, _ := New(Options{TTL: time.Millisecond})
+	if header, body := c.GetConditional("Get", "https://localhost/a"); header != nil || body != nil {
+		t.Errorf("cache: unexpected response %v %s", header, body)
+		return
+	}
+	c.SetConditional("Get", "https://localhost/a", http.Header{"Etag": {`"v1"`}}, []byte("a"))
+	time.Sleep(2 * time.Millisecond)
+	if header, body := c.GetConditional("Get", "https://localhost/a"); header.Get("ETag") != `"v1"` || string(body) != "a" {
+		t.Errorf("cache: unexpected response %v %s", header, body)
+		return
+	}
+	if values := c.GetCache("Get", "https://localhost/a"); values != nil {
+		t.Errorf("cache: unexpected values %v", values)
+		return
+	}
+	c.InvalidateCache("Get")
+	if header, _ := c.GetConditional("Get", "https://localhost/a"); header != nil {
+		t.Errorf("cache: expects invalidated response, got %v", header)
+	}
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// maxDepth bounds the nesting of hashed values, so that cyclic values are not hashed forever.
+const maxDepth = 32
+
+// Key returns a hash of args which is stable across processes, and reports whether args can be hashed. Values are
+// hashed along with their types, structs field by field (including unexported fields), slices and arrays element by
+// element, and maps independently of their order, while values implementing encoding.TextMarshaler (such as time.Time)
+// are hashed by their text. context.Context values are ignored, and functions, channels and io.Reader values (such as
+// request bodies) opt out of caching.
+func Key(args ...any) (string, bool) {
+	h := sha256.New()
+	for _, arg := range args {
+		if _, ok := arg.(context.Context); ok {
+			continue
+		}
+		if !hashValue(h, reflect.ValueOf(arg), 0) {
+			return "", false
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func hashValue(h hash.Hash, v reflect.Value, depth int) bool {
+	if !v.IsValid() {
+		writeString(h, "nil")
+		return true
+	}
+	if depth > maxDepth {
+		return false
+	}
+	writeString(h, v.Type().String())
+	if v.CanInterface() && !isNilPointer(v) {
+		switch value := v.Interface().(type) {
+		case io.Reader:
+			return false
+		case encoding.TextMarshaler:
+			text, err := value.MarshalText()
+			if err != nil {
+				return false
+			}
+			writeString(h, string(text))
+			return true
+		}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		writeString(h, strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeString(h, strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeString(h, strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		writeString(h, strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.Complex64, reflect.Complex128:
+		writeString(h, strconv.FormatComplex(v.Complex(), 'g', -1, 128))
+	case reflect.String:
+		writeString(h, v.String())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			writeString(h, "nil")
+			return true
+		}
+		return hashValue(h, v.Elem(), depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			writeString(h, "nil")
+			return true
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			writeString(h, string(v.Bytes()))
+			return true
+		}
+		fallthrough
+	case reflect.Array:
+		writeString(h, strconv.Itoa(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if !hashValue(h, v.Index(i), depth+1) {
+				return false
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			writeString(h, "nil")
+			return true
+		}
+		// entries are hashed one by one and sorted by their hashes, since the order of maps is random
+		entries := make([]string, 0, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			entry := sha256.New()
+			if !hashValue(entry, iter.Key(), depth+1) || !hashValue(entry, iter.Value(), depth+1) {
+				return false
+			}
+			entries = append(entries, string(entry.Sum(nil)))
+		}
+		sort.Strings(entries)
+		writeString(h, strconv.Itoa(len(entries)))
+		for _, entry := range entries {
+			writeString(h, entry)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			writeString(h, v.Type().Field(i).Name)
+			if !hashValue(h, v.Field(i), depth+1) {
+				return false
+			}
+		}
+	default:
+		// functions, channels and unsafe pointers have no stable value
+		return false
+	}
+	return true
+}
+
+func isNilPointer(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// writeString writes s prefixed by its length, so that consecutive strings cannot be confused.
+func writeString(h hash.Hash, s string) {
+	fmt.Fprintf(h, "%d:%s", len(s), s)
+}
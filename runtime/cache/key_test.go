@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+type keyArgs struct {
+	Name   string
+	Tags   []string
+	Attrs  map[string]int
+	Since  time.Time
+	parent *keyArgs
+}
+
+func TestKey(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newArgs := func() *keyArgs {
+		return &keyArgs{
+			Name:   "defc",
+			Tags:   []string{"a", "b"},
+			Attrs:  map[string]int{"x": 1, "y": 2, "z": 3},
+			Since:  since,
+			parent: &keyArgs{Name: "parent"},
+		}
+	}
+	key, ok := Key(context.Background(), newArgs(), 10)
+	if !ok {
+		t.Errorf("key: expects args to be hashed")
+		return
+	}
+	for i := 0; i < 8; i++ {
+		if other, _ := Key(newArgs(), 10); other != key {
+			t.Errorf("key: %s != %s", other, key)
+			return
+		}
+	}
+	changed := newArgs()
+	changed.parent.Name = "other"
+	for _, args := range [][]any{
+		{changed, 10},
+		{newArgs(), int64(10)},
+		{newArgs(), 10, nil},
+		{"ab", "c"},
+	} {
+		if other, _ := Key(args...); other == key {
+			t.Errorf("key: expects %v to be hashed differently", args)
+			return
+		}
+	}
+	if a, _ := Key("ab", "c"); a == func() string { b, _ := Key("a", "bc"); return b }() {
+		t.Errorf("key: expects consecutive strings to be hashed differently")
+		return
+	}
+	for _, arg := range []any{bytes.NewReader(nil), func() {}, make(chan int), []any{func() {}}} {
+		if _, ok = Key(arg); ok {
+			t.Errorf("key: expects %T to opt out of caching", arg)
+			return
+		}
+	}
+	cyclic := &keyArgs{}
+	cyclic.parent = cyclic
+	if _, ok = Key(cyclic); ok {
+		t.Errorf("key: expects cyclic values to opt out of caching")
+	}
+}
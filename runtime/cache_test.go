@@ -0,0 +1,52 @@
+package defc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2, 0)
+	if values := cache.GetCache("Get", 1); values != nil {
+		t.Errorf("cache: unexpected values %v", values)
+		return
+	}
+	cache.SetCache("Get", []any{1}, "one")
+	cache.SetCache("Get", []any{int64(1)}, "int64 one")
+	if values := cache.GetCache("Get", 1); !reflect.DeepEqual(values, []any{"one"}) {
+		t.Errorf("cache: %v != [one]", values)
+		return
+	}
+	// "Get" of 1 is the most recently used, so that "Get" of int64(1) is evicted
+	cache.SetCache("List", []any{"a", 10}, []string{"a"}, "cursor")
+	if values := cache.GetCache("Get", int64(1)); values != nil {
+		t.Errorf("cache: expects the least recently used entry to be evicted, got %v", values)
+		return
+	}
+	if values := cache.GetCache("List", "a", 10); !reflect.DeepEqual(values, []any{[]string{"a"}, "cursor"}) {
+		t.Errorf("cache: %v != [[a] cursor]", values)
+		return
+	}
+	cache.InvalidateCache("List", "Missing")
+	if values := cache.GetCache("List", "a", 10); values != nil {
+		t.Errorf("cache: expects invalidated entry, got %v", values)
+		return
+	}
+	if values := cache.GetCache("Get", 1); values == nil {
+		t.Errorf("cache: expects entry of other methods to be kept")
+		return
+	}
+	cache.SetCache("Func", []any{func() {}}, "uncacheable")
+	if values := cache.GetCache("Func", func() {}); values != nil {
+		t.Errorf("cache: unexpected values %v", values)
+		return
+	}
+
+	cache = NewCache(0, time.Millisecond)
+	cache.SetCache("Get", []any{1}, "one")
+	time.Sleep(2 * time.Millisecond)
+	if values := cache.GetCache("Get", 1); values != nil {
+		t.Errorf("cache: expects expired entry, got %v", values)
+	}
+}
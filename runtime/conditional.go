@@ -0,0 +1,83 @@
+package defc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ConditionalStore is implemented by the `Options()` type of api schemas with the api/conditional feature, which keeps
+// the header and the body of responses carrying an ETag or a Last-Modified header, keyed by method and URL.
+type ConditionalStore interface {
+	GetConditional(method string, url string) (header http.Header, body []byte)
+	SetConditional(method string, url string, header http.Header, body []byte)
+}
+
+// Revalidation is a conditional request built by Revalidate, whose response is completed by its Response method.
+type Revalidation struct {
+	store  ConditionalStore
+	method string
+	url    string
+	header http.Header
+	body   []byte
+}
+
+// Revalidate adds the If-None-Match and If-Modified-Since headers to request from the response stored for method and
+// the URL of request, if any. It returns nil if options does not implement ConditionalStore, whose Response method is
+// a no-op.
+func Revalidate(options any, method string, request *http.Request) *Revalidation {
+	store, ok := options.(ConditionalStore)
+	if !ok {
+		return nil
+	}
+	revalidation := &Revalidation{store: store, method: method, url: request.URL.String()}
+	revalidation.header, revalidation.body = store.GetConditional(method, revalidation.url)
+	if revalidation.header != nil {
+		if etag := revalidation.header.Get("ETag"); etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := revalidation.header.Get("Last-Modified"); lastModified != "" {
+			request.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	return revalidation
+}
+
+// Response serves a 304 Not Modified response from the stored response as if it were a 200 OK, and stores successful
+// responses carrying an ETag or a Last-Modified header, whose bodies are read before being handed back. Stored bodies
+// are kept as received, so that their Content-Encoding still applies.
+func (revalidation *Revalidation) Response(response *http.Response) error {
+	if revalidation == nil {
+		return nil
+	}
+	switch {
+	case response.StatusCode == http.StatusNotModified && revalidation.header != nil:
+		header := revalidation.header.Clone()
+		// headers of the 304 response update the stored ones, but its body is always empty
+		for key, values := range response.Header {
+			switch key {
+			case "Content-Length", "Content-Encoding", "Content-Type", "Transfer-Encoding":
+				continue
+			}
+			header[key] = values
+		}
+		response.Body.Close()
+		response.StatusCode, response.Status = http.StatusOK, "200 OK"
+		response.Header = header
+		response.Body = io.NopCloser(bytes.NewReader(revalidation.body))
+		response.ContentLength = int64(len(revalidation.body))
+		response.Header.Set("Content-Length", strconv.Itoa(len(revalidation.body)))
+		revalidation.store.SetConditional(revalidation.method, revalidation.url, header, revalidation.body)
+	case response.StatusCode == http.StatusOK &&
+		(response.Header.Get("ETag") != "" || response.Header.Get("Last-Modified") != ""):
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return err
+		}
+		response.Body = io.NopCloser(bytes.NewReader(body))
+		revalidation.store.SetConditional(revalidation.method, revalidation.url, response.Header.Clone(), body)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package defc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testConditionalStore map[string]struct {
+	header http.Header
+	body   []byte
+}
+
+func (s testConditionalStore) GetConditional(method string, url string) (http.Header, []byte) {
+	stored := s[method+" "+url]
+	return stored.header, stored.body
+}
+
+func (s testConditionalStore) SetConditional(method string, url string, header http.Header, body []byte) {
+	s[method+" "+url] = struct {
+		header http.Header
+		body   []byte
+	}{header, body}
+}
+
+func TestRevalidate(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("X-Calls", "304")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"value":"ok"}`))
+	}))
+	defer server.Close()
+	if Revalidate(struct{}{}, "Get", httptest.NewRequest(http.MethodGet, server.URL, nil)).Response(&http.Response{}) != nil {
+		t.Errorf("revalidate: expects a no-op without store")
+		return
+	}
+	store := make(testConditionalStore)
+	for i := 0; i < 2; i++ {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/value", http.NoBody)
+		revalidation := Revalidate(store, "Get", request)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Errorf("revalidate: %s", err)
+			return
+		}
+		if err = revalidation.Response(response); err != nil {
+			t.Errorf("revalidate: %s", err)
+			return
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || string(body) != `{"value":"ok"}` ||
+			response.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("revalidate: unexpected response %d %s %v", response.StatusCode, body, response.Header)
+			return
+		}
+		if i == 1 && response.Header.Get("X-Calls") != "304" {
+			t.Errorf("revalidate: expects the 304 response to be served from the stored response")
+			return
+		}
+	}
+	if calls != 2 {
+		t.Errorf("revalidate: %d != 2", calls)
+	}
+}
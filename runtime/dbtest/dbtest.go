@@ -0,0 +1,216 @@
+// Package dbtest provides helpers for integration tests of code generated in sqlx mode against a real database:
+// opening a database, applying SQL files, loading fixtures, and running each test in a transaction which is rolled
+// back when the test finishes.
+//
+// A typical test looks like:
+//
+//	db := dbtest.Open(t, "sqlite3", ":memory:")
+//	dbtest.ApplyFS(t, db, schemaFS, "schema/*.sql")
+//	dbtest.LoadFixtures(t, db, "testdata/users.yaml")
+//	query := NewUserQueryFromCore(dbtest.Begin(t, db))
+package dbtest
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	__rt "github.com/x5iu/defc/runtime"
+	"github.com/x5iu/defc/sqlx"
+	"github.com/x5iu/defc/sqlx/reflectx"
+)
+
+// Open opens a database for driverName and dsn, and closes it when tb finishes.
+func Open(tb testing.TB, driverName string, dsn string) *sqlx.DB {
+	tb.Helper()
+	db, err := sqlx.Open(driverName, dsn)
+	if err != nil {
+		tb.Fatalf("dbtest: open %s: %s", driverName, err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err = db.Ping(); err != nil {
+		tb.Fatalf("dbtest: ping %s: %s", driverName, err)
+	}
+	return db
+}
+
+// ApplyFiles executes the statements of each SQL file in order, statements are split by ';' with runtime.Split,
+// so that ';' in quoted strings is preserved.
+func ApplyFiles(tb testing.TB, db *sqlx.DB, files ...string) {
+	tb.Helper()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			tb.Fatalf("dbtest: %s", err)
+		}
+		if err = Exec(db, string(content)); err != nil {
+			tb.Fatalf("dbtest: apply %s: %s", file, err)
+		}
+	}
+}
+
+// ApplyFS executes the SQL files of fsys matched by patterns, the files matched by one pattern are applied in
+// lexical order. A pattern naming a directory matches all the .sql files in it.
+func ApplyFS(tb testing.TB, db *sqlx.DB, fsys fs.FS, patterns ...string) {
+	tb.Helper()
+	files, err := globFS(fsys, patterns, ".sql")
+	if err != nil {
+		tb.Fatalf("dbtest: %s", err)
+	}
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			tb.Fatalf("dbtest: %s", err)
+		}
+		if err = Exec(db, string(content)); err != nil {
+			tb.Fatalf("dbtest: apply %s: %s", file, err)
+		}
+	}
+}
+
+// Exec splits script by ';' and executes each statement on db.
+func Exec(db *sqlx.DB, script string) error {
+	for _, stmt := range __rt.Split(script, ";") {
+		if strings.TrimSpace(strings.Trim(strings.TrimSpace(stmt), ";")) == "" {
+			continue
+		}
+		if _, err := db.ExecContext(context.Background(), stmt); err != nil {
+			return fmt.Errorf("%w\n\n%s", err, strings.TrimSpace(stmt))
+		}
+	}
+	return nil
+}
+
+func globFS(fsys fs.FS, patterns []string, exts ...string) ([]string, error) {
+	files := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		globs := []string{pattern}
+		if info, err := fs.Stat(fsys, pattern); err == nil && info.IsDir() {
+			globs = globs[:0]
+			for _, ext := range exts {
+				globs = append(globs, path.Join(pattern, "*"+ext))
+			}
+		}
+		matches := make([]string, 0, 8)
+		for _, glob := range globs {
+			globMatches, err := fs.Glob(fsys, glob)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, globMatches...)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no file matches %q", pattern)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// Insert inserts rows into table, each row is either a map keyed by column names, or a struct (or a pointer to a
+// struct) whose fields are mapped to columns by the `db` tag mapper of db, in the same way as they are scanned.
+func Insert(tb testing.TB, db *sqlx.DB, table string, rows ...any) {
+	tb.Helper()
+	for _, row := range rows {
+		columns, values, err := rowColumns(db.Mapper, row)
+		if err != nil {
+			tb.Fatalf("dbtest: insert into %s: %s", table, err)
+		}
+		if err = insert(db, table, columns, values); err != nil {
+			tb.Fatalf("dbtest: insert into %s: %s", table, err)
+		}
+	}
+}
+
+func insert(db *sqlx.DB, table string, columns []string, values []any) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders)
+	_, err := db.ExecContext(context.Background(), db.Rebind(query), values...)
+	return err
+}
+
+func rowColumns(mapper *reflectx.Mapper, row any) ([]string, []any, error) {
+	if m, ok := row.(map[string]any); ok {
+		columns := make([]string, 0, len(m))
+		for column := range m {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		values := make([]any, len(columns))
+		for i, column := range columns {
+			values[i] = m[column]
+		}
+		return columns, values, nil
+	}
+	v := reflect.Indirect(reflect.ValueOf(row))
+	if v.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("unsupported row type %T, expects map[string]any or struct", row)
+	}
+	var (
+		fields  = append([]*reflectx.FieldInfo(nil), mapper.TypeMap(v.Type()).Index...)
+		columns = make([]string, 0, len(fields))
+		values  = make([]any, 0, len(fields))
+	)
+	// The index of a StructMap is in breadth-first order, columns are sorted in the order of declaration instead.
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].Index, fields[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	for _, field := range fields {
+		// Fields of nested (non-embedded) structs have dotted paths, and are not columns of the table.
+		if field.Embedded || field.Name == "" || strings.Contains(field.Path, ".") || !isColumnType(field.Field.Type) {
+			continue
+		}
+		value := fieldByIndex(v, field.Index)
+		if !value.IsValid() || !value.CanInterface() {
+			continue
+		}
+		columns = append(columns, field.Name)
+		values = append(values, value.Interface())
+	}
+	return columns, values, nil
+}
+
+var (
+	valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+// isColumnType reports whether a field of type t holds a column value, struct fields are columns only when they are
+// driver.Valuer or time.Time, otherwise they are nested structs mapped to dotted paths.
+func isColumnType(t reflect.Type) bool {
+	if t.Implements(valuerType) || reflect.PointerTo(t).Implements(valuerType) {
+		return true
+	}
+	t = reflectx.Deref(t)
+	return t.Kind() != reflect.Struct || t == timeType
+}
+
+// fieldByIndex is reflectx.FieldByIndexesReadOnly which returns an invalid reflect.Value instead of panicking when
+// an embedded struct pointer is nil.
+func fieldByIndex(v reflect.Value, indexes []int) reflect.Value {
+	for _, i := range indexes {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
@@ -0,0 +1,221 @@
+package dbtest
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/x5iu/defc/runtime/sqlxtest"
+)
+
+func newCore(t *testing.T) *sqlxtest.Core {
+	core := sqlxtest.New("sqlite3")
+	t.Cleanup(func() { core.Close() })
+	core.MatchInOrder(false)
+	core.ExpectExecRegexp(`.*`).AnyTimes()
+	return core
+}
+
+func queries(core *sqlxtest.Core) []string {
+	statements := core.Statements()
+	queries := make([]string, len(statements))
+	for i, statement := range statements {
+		queries[i] = strings.Join(strings.Fields(statement.Query), " ")
+	}
+	return queries
+}
+
+func TestApplyFS(t *testing.T) {
+	core := newCore(t)
+	fsys := fstest.MapFS{
+		"schema/0002_projects.sql": {Data: []byte("CREATE TABLE projects (id INTEGER, user_id INTEGER);\n")},
+		"schema/0001_users.sql": {Data: []byte("" +
+			"CREATE TABLE users (id INTEGER, name TEXT DEFAULT ';');\n" +
+			"CREATE INDEX users_name ON users (name);\n")},
+		"schema/README.md": {Data: []byte("not sql")},
+	}
+	ApplyFS(t, core.DB, fsys, "schema")
+	expect := []string{
+		"CREATE TABLE users (id INTEGER, name TEXT DEFAULT ';');",
+		"CREATE INDEX users_name ON users (name);",
+		"CREATE TABLE projects (id INTEGER, user_id INTEGER);",
+	}
+	if got := queries(core); !reflect.DeepEqual(got, expect) {
+		t.Errorf("apply: %q != %q", got, expect)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	type Base struct {
+		ID int64 `db:"id"`
+	}
+	type Profile struct {
+		Bio string `db:"bio"`
+	}
+	type User struct {
+		*Base
+		Name    string    `db:"name"`
+		Email   *string   `db:"email"`
+		Profile Profile   `db:"profile"`
+		Created time.Time `db:"created"`
+		secret  string
+	}
+	core := newCore(t)
+	Insert(t, core.DB, "users",
+		&User{Base: &Base{ID: 1}, Name: "defc", secret: "x"},
+		User{Name: "sqlx"},
+		map[string]any{"name": "map", "id": 3},
+	)
+	statements := core.Statements()
+	expect := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO users (id, name, email, created) VALUES (?, ?, ?, ?)", []any{int64(1), "defc", nil, time.Time{}}},
+		{"INSERT INTO users (name, email, created) VALUES (?, ?, ?)", []any{"sqlx", nil, time.Time{}}},
+		{"INSERT INTO users (id, name) VALUES (?, ?)", []any{int64(3), "map"}},
+	}
+	if len(statements) != len(expect) {
+		t.Fatalf("insert: %d statements != %d", len(statements), len(expect))
+	}
+	for i, statement := range statements {
+		if statement.Query != expect[i].query {
+			t.Errorf("insert: %q != %q", statement.Query, expect[i].query)
+		}
+		if !reflect.DeepEqual(statement.Args, expect[i].args) {
+			t.Errorf("insert: %#v != %#v", statement.Args, expect[i].args)
+		}
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	core := newCore(t)
+	fsys := fstest.MapFS{
+		"fixtures/1_users.yaml": {Data: []byte("" +
+			"# users\n" +
+			"users:\n" +
+			"  - id: 1\n" +
+			"    name: O'Brien # comment\n" +
+			"    note: \"#1, 'quoted'\"\n" +
+			"    active: true\n" +
+			"  - {id: 2, name: 'it''s', score: 1.5, deleted_at: null}\n" +
+			"projects: []\n")},
+		"fixtures/2_projects.json": {Data: []byte(`{"projects": [{"id": 1, "user_id": 1}], "tags": [{"name": "go"}]}`)},
+	}
+	LoadFixturesFS(t, core.DB, fsys, "fixtures")
+	statements := core.Statements()
+	expect := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO users (active, id, name, note) VALUES (?, ?, ?, ?)", []any{true, int64(1), "O'Brien", "#1, 'quoted'"}},
+		{"INSERT INTO users (deleted_at, id, name, score) VALUES (?, ?, ?, ?)", []any{nil, int64(2), "it's", 1.5}},
+		{"INSERT INTO projects (id, user_id) VALUES (?, ?)", []any{int64(1), int64(1)}},
+		{"INSERT INTO tags (name) VALUES (?)", []any{"go"}},
+	}
+	if len(statements) != len(expect) {
+		t.Fatalf("fixtures: %d statements != %d", len(statements), len(expect))
+	}
+	for i, statement := range statements {
+		if statement.Query != expect[i].query {
+			t.Errorf("fixtures: %q != %q", statement.Query, expect[i].query)
+		}
+		if !reflect.DeepEqual(statement.Args, expect[i].args) {
+			t.Errorf("fixtures: %#v != %#v", statement.Args, expect[i].args)
+		}
+	}
+}
+
+func TestParseYAMLFixtures(t *testing.T) {
+	tables, err := parseYAMLFixtures([]byte("" +
+		"base:\n" +
+		"  - &base {active: true}\n" +
+		"users:\n" +
+		"  - <<: *base\n" +
+		"    id: 1\n" +
+		"    bio: |\n" +
+		"      line 1\n" +
+		"      line 2\n" +
+		"    name: \"defc\" # comment\n" +
+		"  - id: 0x10\n" +
+		"    name: 'it''s'\n"))
+	if err != nil {
+		t.Fatalf("yaml: %s", err)
+	}
+	if len(tables) != 2 || tables[0].name != "base" || tables[1].name != "users" {
+		t.Fatalf("yaml: unexpected tables %v", tables)
+	}
+	expect := []map[string]any{
+		{"active": true, "id": int64(1), "bio": "line 1\nline 2\n", "name": "defc"},
+		{"id": int64(16), "name": "it's"},
+	}
+	if !reflect.DeepEqual(tables[1].rows, expect) {
+		t.Errorf("yaml: %#v != %#v", tables[1].rows, expect)
+	}
+	for _, content := range []string{
+		"  - id: 1\n",
+		"users:\n  id: 1\n",
+		"users: 1\n",
+		"users:\n  - {id: 1\n",
+	} {
+		if _, err := parseYAMLFixtures([]byte(content)); err == nil {
+			t.Errorf("yaml: expects error for %q", content)
+		}
+	}
+}
+
+func TestBegin(t *testing.T) {
+	core := newCore(t)
+	core.ExpectQuery("SELECT id, name FROM users").
+		WillReturnRows(sqlxtest.NewRows("id", "name").AddRow(1, []byte("defc")))
+	t.Run("rollback", func(t *testing.T) {
+		ctx := context.Background()
+		db := Begin(t, core.DB)
+		if db.DriverName() != "sqlite3" {
+			t.Errorf("begin: driver name %q", db.DriverName())
+		}
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			t.Fatalf("begin: %s", err)
+		}
+		if _, err = tx.ExecContext(ctx, tx.Rebind("INSERT INTO users (name) VALUES (?)"), "defc"); err != nil {
+			t.Fatalf("exec: %s", err)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatalf("commit: %s", err)
+		}
+		if tx, err = db.BeginTxx(ctx, nil); err != nil {
+			t.Fatalf("begin: %s", err)
+		}
+		if err = tx.Rollback(); err != nil {
+			t.Fatalf("rollback: %s", err)
+		}
+		var users []struct {
+			ID   int64  `db:"id"`
+			Name string `db:"name"`
+		}
+		if err = db.SelectContext(ctx, &users, "SELECT id, name FROM users"); err != nil {
+			t.Fatalf("select: %s", err)
+		}
+		if len(users) != 1 || users[0].ID != 1 || users[0].Name != "defc" {
+			t.Errorf("select: unexpected %v", users)
+		}
+	})
+	expect := []string{
+		"SAVEPOINT defc_dbtest_1",
+		"INSERT INTO users (name) VALUES (?)",
+		"RELEASE SAVEPOINT defc_dbtest_1",
+		"SAVEPOINT defc_dbtest_2",
+		"ROLLBACK TO SAVEPOINT defc_dbtest_2",
+		"SELECT id, name FROM users",
+	}
+	if got := queries(core); !reflect.DeepEqual(got, expect) {
+		t.Errorf("begin: %q != %q", got, expect)
+	}
+	if begins, commits, rollbacks := core.Transactions(); begins != 1 || commits != 0 || rollbacks != 1 {
+		t.Errorf("begin: transactions (%d, %d, %d) != (1, 0, 1)", begins, commits, rollbacks)
+	}
+}
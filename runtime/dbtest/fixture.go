@@ -0,0 +1,162 @@
+package dbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/x5iu/defc/sqlx"
+)
+
+// LoadFixtures inserts the rows of each fixture file into the database, in the order of files and of the tables in
+// each file. A fixture file maps table names to lists of rows, each row maps column names to values:
+//
+//	users:
+//	  - id: 1
+//	    name: defc
+//	  - {id: 2, name: "sqlx"}
+//	projects: []
+//
+// Files are decoded according to their extension, .yaml/.yml files are decoded as YAML documents, and .json files
+// are decoded as JSON objects of the same shape.
+func LoadFixtures(tb testing.TB, db *sqlx.DB, files ...string) {
+	tb.Helper()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			tb.Fatalf("dbtest: %s", err)
+		}
+		if err = loadFixtures(db, file, content); err != nil {
+			tb.Fatalf("dbtest: load fixtures %s: %s", file, err)
+		}
+	}
+}
+
+// LoadFixturesFS is LoadFixtures for the files of fsys matched by patterns, see ApplyFS for how patterns are
+// matched, a pattern naming a directory matches all the .json, .yaml and .yml files in it.
+func LoadFixturesFS(tb testing.TB, db *sqlx.DB, fsys fs.FS, patterns ...string) {
+	tb.Helper()
+	files, err := globFS(fsys, patterns, ".json", ".yaml", ".yml")
+	if err != nil {
+		tb.Fatalf("dbtest: %s", err)
+	}
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			tb.Fatalf("dbtest: %s", err)
+		}
+		if err = loadFixtures(db, file, content); err != nil {
+			tb.Fatalf("dbtest: load fixtures %s: %s", file, err)
+		}
+	}
+}
+
+type fixtureTable struct {
+	name string
+	rows []map[string]any
+}
+
+func loadFixtures(db *sqlx.DB, file string, content []byte) error {
+	tables, err := parseFixtures(file, content)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		for _, row := range table.rows {
+			columns, values, err := rowColumns(db.Mapper, row)
+			if err != nil {
+				return err
+			}
+			if err = insert(db, table.name, columns, values); err != nil {
+				return fmt.Errorf("insert into %s: %w", table.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func parseFixtures(file string, content []byte) ([]*fixtureTable, error) {
+	switch ext := path.Ext(file); ext {
+	case ".json":
+		return parseJSONFixtures(content)
+	case ".yaml", ".yml":
+		return parseYAMLFixtures(content)
+	default:
+		return nil, fmt.Errorf("unsupported fixture file extension %q", ext)
+	}
+}
+
+// parseJSONFixtures reads the top level object token by token to keep the order of tables.
+func parseJSONFixtures(content []byte) ([]*fixtureTable, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, fmt.Errorf("expects an object of tables, got %v", token)
+	}
+	tables := make([]*fixtureTable, 0, 4)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		table := &fixtureTable{name: token.(string)}
+		if err = decoder.Decode(&table.rows); err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.name, err)
+		}
+		for _, row := range table.rows {
+			for column, value := range row {
+				if number, ok := value.(json.Number); ok {
+					if i, err := number.Int64(); err == nil {
+						row[column] = i
+					} else if f, err := number.Float64(); err == nil {
+						row[column] = f
+					} else {
+						row[column] = number.String()
+					}
+				}
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// parseYAMLFixtures decodes the top level mapping as a yaml.Node to keep the order of tables.
+func parseYAMLFixtures(content []byte) ([]*fixtureTable, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expects a mapping of tables", mapping.Line)
+	}
+	tables := make([]*fixtureTable, 0, len(mapping.Content)/2)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		table := &fixtureTable{name: mapping.Content[i].Value}
+		if err := mapping.Content[i+1].Decode(&table.rows); err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.name, err)
+		}
+		for _, row := range table.rows {
+			for column, value := range row {
+				// integers are decoded as int64, the same as in JSON fixtures
+				if i, ok := value.(int); ok {
+					row[column] = int64(i)
+				}
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
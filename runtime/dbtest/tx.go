@@ -0,0 +1,170 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	__rt "github.com/x5iu/defc/runtime"
+	"github.com/x5iu/defc/sqlx"
+)
+
+// Begin starts a transaction on db which is rolled back when tb finishes, and returns a *sqlx.DB whose statements
+// all run in that transaction. It is meant to be passed to NewXFromCore (or NewXFromDB), so that the changes made by
+// a test are discarded, while the generated code still begins, commits and rolls back transactions as usual:
+// transactions begun on the returned *sqlx.DB are savepoints of the outer transaction.
+//
+// Since a savepoint cannot change the isolation level of the outer transaction, the isolation level requested by
+// the generated code (the ISOLATION option of WithTx) is ignored.
+func Begin(tb testing.TB, db *sqlx.DB) *sqlx.DB {
+	tb.Helper()
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		tb.Fatalf("dbtest: begin: %s", err)
+	}
+	c := &connector{tx: tx, dialect: __rt.Dialect(db.DriverName())}
+	wrapped := sqlx.NewDB(sql.OpenDB(c), db.DriverName())
+	wrapped.Mapper = db.Mapper
+	// *sql.Tx does not support concurrent statements, all of them are serialized by a single connection.
+	wrapped.SetMaxOpenConns(1)
+	tb.Cleanup(func() {
+		wrapped.Close()
+		tx.Rollback()
+	})
+	return wrapped
+}
+
+type connector struct {
+	tx      *sql.Tx
+	dialect string
+
+	mu         sync.Mutex
+	savepoints int
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) { return &conn{connector: c}, nil }
+func (c *connector) Driver() driver.Driver                        { return txDriver{} }
+
+type txDriver struct{}
+
+func (txDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("dbtest: use dbtest.Begin to create a transactional database")
+}
+
+type conn struct {
+	connector *connector
+}
+
+func (c *conn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("dbtest: prepared statements are not supported")
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	c.connector.mu.Lock()
+	c.connector.savepoints++
+	name := fmt.Sprintf("defc_dbtest_%d", c.connector.savepoints)
+	c.connector.mu.Unlock()
+	sp := &savepoint{tx: c.connector.tx, name: name, dialect: c.connector.dialect}
+	if err := sp.exec(ctx, "SAVEPOINT %s", "SAVE TRANSACTION %s"); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.connector.tx.ExecContext(ctx, query, namedArgs(args)...)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rs, err := c.connector.tx.QueryContext(ctx, query, namedArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := rs.Columns()
+	if err != nil {
+		rs.Close()
+		return nil, err
+	}
+	return &rows{rows: rs, columns: columns}, nil
+}
+
+// CheckNamedValue passes all arguments through as-is, they are converted by the driver of the outer transaction.
+func (c *conn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func namedArgs(args []driver.NamedValue) []any {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			values[i] = sql.Named(arg.Name, arg.Value)
+		} else {
+			values[i] = arg.Value
+		}
+	}
+	return values
+}
+
+type savepoint struct {
+	tx      *sql.Tx
+	name    string
+	dialect string
+}
+
+func (sp *savepoint) Commit() error {
+	// SQL Server and Oracle have no statement to release a savepoint, it is released with the outer transaction.
+	if sp.dialect == __rt.DialectSQLServer || sp.dialect == __rt.DialectOracle {
+		return nil
+	}
+	return sp.exec(context.Background(), "RELEASE SAVEPOINT %s", "")
+}
+
+func (sp *savepoint) Rollback() error {
+	return sp.exec(context.Background(), "ROLLBACK TO SAVEPOINT %s", "ROLLBACK TRANSACTION %s")
+}
+
+func (sp *savepoint) exec(ctx context.Context, format string, sqlserverFormat string) error {
+	if sp.dialect == __rt.DialectSQLServer {
+		format = sqlserverFormat
+	}
+	_, err := sp.tx.ExecContext(ctx, fmt.Sprintf(format, sp.name))
+	return err
+}
+
+type rows struct {
+	rows    *sql.Rows
+	columns []string
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return r.rows.Close() }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	// Scanning into *any copies []byte values, which may be reused by the driver of the outer transaction.
+	values := make([]any, len(dest))
+	pointers := make([]any, len(dest))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := r.rows.Scan(pointers...); err != nil {
+		return err
+	}
+	for i, value := range values {
+		dest[i] = value
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package defc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WithDeadline returns a shallow copy of request whose context is done at deadline, or after timeout if it comes
+// first. A zero deadline or a non-positive timeout is ignored, and request is returned as is when both are. The
+// returned cancel function should be called once the response is no longer used, see CancelOnClose.
+func WithDeadline(request *http.Request, deadline time.Time, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	if timeout > 0 {
+		if attemptDeadline := time.Now().Add(timeout); deadline.IsZero() || attemptDeadline.Before(deadline) {
+			deadline = attemptDeadline
+		}
+	}
+	if deadline.IsZero() {
+		return request, func() {}
+	}
+	ctx, cancel := context.WithDeadline(request.Context(), deadline)
+	return request.WithContext(ctx), cancel
+}
+
+// CancelOnClose calls cancel when the body of response is closed, so that the context of a request with a deadline
+// lives as long as its response body is being read, instead of ending when the method sending it returns.
+func CancelOnClose(response *http.Response, cancel context.CancelFunc) {
+	response.Body = &cancelReadCloser{ReadCloser: response.Body, cancel: cancel}
+}
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
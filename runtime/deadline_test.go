@@ -0,0 +1,54 @@
+package defc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithDeadline(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	if withDeadline, cancel := WithDeadline(request, time.Time{}, 0); withDeadline != request {
+		t.Errorf("deadline: expects the same request without deadline")
+		return
+	} else {
+		cancel()
+	}
+	deadline := time.Now().Add(time.Hour)
+	withDeadline, cancel := WithDeadline(request, deadline, 0)
+	if d, ok := withDeadline.Context().Deadline(); !ok || !d.Equal(deadline) {
+		t.Errorf("deadline: %s != %s", d, deadline)
+		return
+	}
+	cancel()
+	withDeadline, cancel = WithDeadline(request, deadline, time.Millisecond)
+	defer cancel()
+	if d, ok := withDeadline.Context().Deadline(); !ok || !d.Before(deadline) {
+		t.Errorf("deadline: expects the attempt timeout to come first, got %s", d)
+		return
+	}
+	<-withDeadline.Context().Done()
+	if err := withDeadline.Context().Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deadline: %v != %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCancelOnClose(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		response    = &http.Response{Body: io.NopCloser(bytes.NewReader([]byte("test")))}
+	)
+	CancelOnClose(response, cancel)
+	if body, _ := io.ReadAll(response.Body); string(body) != "test" || ctx.Err() != nil {
+		t.Errorf("cancel: unexpected body %q or context error %v", body, ctx.Err())
+		return
+	}
+	response.Body.Close()
+	if ctx.Err() == nil {
+		t.Errorf("cancel: expects context to be canceled when body is closed")
+	}
+}
@@ -0,0 +1,69 @@
+package defc
+
+import (
+	"sync"
+
+	"github.com/x5iu/defc/sqlx"
+)
+
+const (
+	DialectPostgres  = "postgres"
+	DialectMySQL     = "mysql"
+	DialectSQLite    = "sqlite"
+	DialectSQLServer = "sqlserver"
+	DialectOracle    = "oracle"
+)
+
+var defaultDialects = map[string][]string{
+	DialectPostgres:  {"postgres", "pgx", "pq-timeouts", "cloudsqlpostgres", "nrpostgres", "cockroach"},
+	DialectMySQL:     {"mysql", "nrmysql"},
+	DialectSQLite:    {"sqlite3", "sqlite", "nrsqlite3"},
+	DialectSQLServer: {"sqlserver", "azuresql", "mssql"},
+	DialectOracle:    {"oci8", "ora", "goracle", "godror"},
+}
+
+var dialects sync.Map
+
+func init() {
+	for dialect, drivers := range defaultDialects {
+		for _, driver := range drivers {
+			RegisterDialect(driver, dialect)
+		}
+	}
+}
+
+// RegisterDialect sets the SQL dialect used for driverName.
+func RegisterDialect(driverName string, dialect string) {
+	dialects.Store(driverName, dialect)
+}
+
+// Dialect returns the SQL dialect of driverName. Drivers that have not been registered fall back
+// to the dialect implied by their sqlx.BindType, or to the driver name itself when it is unknown.
+func Dialect(driverName string) string {
+	if dialect, ok := dialects.Load(driverName); ok {
+		return dialect.(string)
+	}
+	switch sqlx.BindType(driverName) {
+	case sqlx.DOLLAR:
+		return DialectPostgres
+	case sqlx.AT:
+		return DialectSQLServer
+	case sqlx.NAMED:
+		return DialectOracle
+	default:
+		return driverName
+	}
+}
+
+// DriverName returns the driver name reported by the first value that has a DriverName method,
+// such as *sqlx.DB or *sqlx.Tx, or an empty string if there is none.
+func DriverName(values ...any) string {
+	for _, value := range values {
+		if driver, ok := value.(interface{ DriverName() string }); ok {
+			if name := driver.DriverName(); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
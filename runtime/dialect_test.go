@@ -0,0 +1,50 @@
+package defc
+
+import "testing"
+
+type implDriverName string
+
+func (name implDriverName) DriverName() string { return string(name) }
+
+func TestDialect(t *testing.T) {
+	type TestCase struct {
+		Name   string
+		Driver string
+		Expect string
+	}
+	var testcases = []*TestCase{
+		{Name: "pgx", Driver: "pgx", Expect: DialectPostgres},
+		{Name: "mysql", Driver: "mysql", Expect: DialectMySQL},
+		{Name: "sqlite3", Driver: "sqlite3", Expect: DialectSQLite},
+		{Name: "azuresql", Driver: "azuresql", Expect: DialectSQLServer},
+		{Name: "godror", Driver: "godror", Expect: DialectOracle},
+		{Name: "bind_type", Driver: "clickhouse", Expect: DialectPostgres},
+		{Name: "unknown", Driver: "unknown", Expect: "unknown"},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.Name, func(t *testing.T) {
+			if dialect := Dialect(testcase.Driver); dialect != testcase.Expect {
+				t.Errorf("dialect: %q != %q", dialect, testcase.Expect)
+				return
+			}
+		})
+	}
+	t.Run("register", func(t *testing.T) {
+		RegisterDialect("test_dialect_driver", DialectMySQL)
+		if dialect := Dialect("test_dialect_driver"); dialect != DialectMySQL {
+			t.Errorf("dialect: %q != %q", dialect, DialectMySQL)
+			return
+		}
+	})
+}
+
+func TestDriverName(t *testing.T) {
+	if name := DriverName(nil, struct{}{}, implDriverName(""), implDriverName("sqlite3")); name != "sqlite3" {
+		t.Errorf("driver name: %q != %q", name, "sqlite3")
+		return
+	}
+	if name := DriverName(struct{}{}); name != "" {
+		t.Errorf("driver name: expects empty string, got %q", name)
+		return
+	}
+}
@@ -0,0 +1,123 @@
+package defc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var defaultExplains = map[string]string{
+	DialectPostgres: "EXPLAIN ",
+	DialectMySQL:    "EXPLAIN ",
+	DialectSQLite:   "EXPLAIN QUERY PLAN ",
+}
+
+var explains sync.Map
+
+func init() {
+	for dialect, prefix := range defaultExplains {
+		RegisterExplain(dialect, prefix)
+	}
+}
+
+// RegisterExplain sets the statement prefix used to explain queries of dialect, for example
+// "EXPLAIN " for postgres or "EXPLAIN QUERY PLAN " for sqlite.
+func RegisterExplain(dialect string, prefix string) {
+	explains.Store(dialect, prefix)
+}
+
+var explainableStatements = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "WITH"}
+
+// ExplainQuery returns the statement that explains query for driverName, it reports false when the
+// dialect of driverName has no registered EXPLAIN prefix, or when query is not a DML statement
+// (explaining DDL is an error in most databases and may abort the current transaction).
+func ExplainQuery(driverName string, query string) (string, bool) {
+	prefix, ok := explains.Load(Dialect(driverName))
+	if !ok {
+		return "", false
+	}
+	fields := strings.Fields(strings.TrimLeft(query, " \t\r\n("))
+	if len(fields) == 0 {
+		return "", false
+	}
+	for _, stmt := range explainableStatements {
+		if strings.EqualFold(fields[0], stmt) {
+			return prefix.(string) + query, true
+		}
+	}
+	return "", false
+}
+
+var ErrExplainNotSupported = errors.New("defc: explain is not supported")
+
+// explainSavepoint is the savepoint Explain runs its EXPLAIN statement under.
+const explainSavepoint = "defc_explain"
+
+// Explain runs the EXPLAIN statement of query on tx with the same args, and returns the plan as one
+// map per row keyed by column name. tx is usually the transaction that has just executed query, the
+// EXPLAIN statement runs under a savepoint which is rolled back when it fails, so that a failed EXPLAIN
+// does not abort the transaction (as postgres does).
+func Explain(ctx context.Context, tx any, driverName string, query string, args ...any) (plan []map[string]any, err error) {
+	explainQuery, ok := ExplainQuery(driverName, query)
+	if !ok {
+		return nil, ErrExplainNotSupported
+	}
+	queryer, ok := tx.(interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	})
+	if !ok {
+		return nil, ErrExplainNotSupported
+	}
+	if _, err = queryer.ExecContext(ctx, "SAVEPOINT "+explainSavepoint); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			if _, rollbackErr := queryer.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+explainSavepoint); rollbackErr != nil {
+				err = fmt.Errorf("%w (rolling back to savepoint: %s)", err, rollbackErr)
+			}
+			return
+		}
+		_, err = queryer.ExecContext(ctx, "RELEASE SAVEPOINT "+explainSavepoint)
+	}()
+	return explain(ctx, queryer, explainQuery, args...)
+}
+
+func explain(ctx context.Context, queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}, explainQuery string, args ...any) ([]map[string]any, error) {
+	rows, err := queryer.QueryContext(ctx, explainQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	plan := make([]map[string]any, 0, 4)
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			if b, isBytes := values[i].([]byte); isBytes {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		plan = append(plan, row)
+	}
+	return plan, rows.Err()
+}
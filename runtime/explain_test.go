@@ -0,0 +1,161 @@
+package defc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// stubDriver is a minimal database/sql driver which records executed queries and returns its
+// configured columns and values (or its configured error) for every query.
+type stubDriver struct {
+	queries []string
+	columns []string
+	values  [][]driver.Value
+	err     error
+}
+
+func (d *stubDriver) Open(string) (driver.Conn, error) { return &stubConn{driver: d}, nil }
+
+type stubConn struct{ driver *stubDriver }
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return &stubStmt{conn: c, query: query}, nil
+}
+func (c *stubConn) Close() error              { return nil }
+func (c *stubConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *stubConn) Commit() error             { return nil }
+func (c *stubConn) Rollback() error           { return nil }
+
+type stubStmt struct {
+	conn  *stubConn
+	query string
+}
+
+func (s *stubStmt) Close() error  { return nil }
+func (s *stubStmt) NumInput() int { return -1 }
+func (s *stubStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.conn.driver.queries = append(s.conn.driver.queries, s.query)
+	return driver.RowsAffected(0), nil
+}
+func (s *stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.conn.driver.queries = append(s.conn.driver.queries, s.query)
+	if s.conn.driver.err != nil {
+		return nil, s.conn.driver.err
+	}
+	return &stubRows{columns: s.conn.driver.columns, values: s.conn.driver.values}, nil
+}
+
+type stubRows struct {
+	columns []string
+	values  [][]driver.Value
+	index   int
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+func (r *stubRows) Close() error      { return nil }
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.index])
+	r.index++
+	return nil
+}
+
+func TestExplainQuery(t *testing.T) {
+	type TestCase struct {
+		Name   string
+		Driver string
+		Query  string
+		Expect string
+		Ok     bool
+	}
+	var testcases = []*TestCase{
+		{Name: "postgres", Driver: "postgres", Query: "SELECT * FROM user WHERE id = $1", Expect: "EXPLAIN SELECT * FROM user WHERE id = $1", Ok: true},
+		{Name: "sqlite", Driver: "sqlite3", Query: "\n  update user set name = ?", Expect: "EXPLAIN QUERY PLAN \n  update user set name = ?", Ok: true},
+		{Name: "parenthesized", Driver: "mysql", Query: "(SELECT 1) UNION (SELECT 2)", Expect: "EXPLAIN (SELECT 1) UNION (SELECT 2)", Ok: true},
+		{Name: "ddl", Driver: "postgres", Query: "CREATE TABLE user (id INTEGER)", Ok: false},
+		{Name: "unsupported", Driver: "sqlserver", Query: "SELECT 1", Ok: false},
+		{Name: "empty", Driver: "mysql", Query: " ", Ok: false},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.Name, func(t *testing.T) {
+			query, ok := ExplainQuery(testcase.Driver, testcase.Query)
+			if ok != testcase.Ok {
+				t.Errorf("explain: ok %v != %v", ok, testcase.Ok)
+				return
+			}
+			if query != testcase.Expect {
+				t.Errorf("explain: %q != %q", query, testcase.Expect)
+				return
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	stub := &stubDriver{
+		columns: []string{"id", "detail"},
+		values: [][]driver.Value{
+			{int64(2), []byte("SCAN user")},
+		},
+	}
+	sql.Register("defc_explain_stub", stub)
+	db, err := sql.Open("defc_explain_stub", "")
+	if err != nil {
+		t.Errorf("open: %s", err)
+		return
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Errorf("begin: %s", err)
+		return
+	}
+	defer tx.Rollback()
+	RegisterDialect("defc_explain_stub", DialectSQLite)
+	plan, err := Explain(context.Background(), tx, "defc_explain_stub", "SELECT * FROM user WHERE name = ?", "defc")
+	if err != nil {
+		t.Errorf("explain: %s", err)
+		return
+	}
+	if expect := []map[string]any{{"id": int64(2), "detail": "SCAN user"}}; !reflect.DeepEqual(plan, expect) {
+		t.Errorf("explain: %v != %v", plan, expect)
+		return
+	}
+	if expect := []string{
+		"SAVEPOINT defc_explain",
+		"EXPLAIN QUERY PLAN SELECT * FROM user WHERE name = ?",
+		"RELEASE SAVEPOINT defc_explain",
+	}; !reflect.DeepEqual(stub.queries, expect) {
+		t.Errorf("explain: queries %v != %v", stub.queries, expect)
+		return
+	}
+	stub.queries, stub.err = nil, errors.New("syntax error")
+	if _, err = Explain(context.Background(), tx, "defc_explain_stub", "SELECT * FROM user"); err != stub.err {
+		t.Errorf("explain: expects %v, got %v", stub.err, err)
+		return
+	}
+	if expect := []string{
+		"SAVEPOINT defc_explain",
+		"EXPLAIN QUERY PLAN SELECT * FROM user",
+		"ROLLBACK TO SAVEPOINT defc_explain",
+	}; !reflect.DeepEqual(stub.queries, expect) {
+		t.Errorf("explain: queries %v != %v", stub.queries, expect)
+		return
+	}
+	stub.err = nil
+	if _, err = Explain(context.Background(), tx, "defc_explain_stub", "DROP TABLE user"); !errors.Is(err, ErrExplainNotSupported) {
+		t.Errorf("explain: expects ErrExplainNotSupported, got %v", err)
+		return
+	}
+	if _, err = Explain(context.Background(), struct{}{}, "defc_explain_stub", "SELECT 1"); !errors.Is(err, ErrExplainNotSupported) {
+		t.Errorf("explain: expects ErrExplainNotSupported, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,213 @@
+package defc
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interpolate replaces the placeholders (?, $N, @pN and :argN) in query with the literal values of args,
+// quoting strings, bytes and times in the way the dialect of driverName expects. args is usually the
+// value passed to a sqlx/log hook, which is either a slice of arguments or a single argument.
+//
+// Placeholders inside quotes and comments are left untouched, and the text between placeholders is
+// copied as is. Placeholders without a matching argument are kept, and unused arguments are ignored.
+//
+// The result is meant to be copied into a SQL console for debugging, it is NOT safe to execute it
+// programmatically; always pass arguments to the database separately.
+func Interpolate(driverName string, query string, args any) string {
+	var (
+		dialect = Dialect(driverName)
+		values  = interpolateArgs(args)
+		output  strings.Builder
+		quote   byte
+		n       int
+	)
+	output.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		if quote != 0 {
+			if ch == quote && query[i-1] != '\\' {
+				quote = 0
+			}
+			output.WriteByte(ch)
+			continue
+		}
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			output.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			output.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		default:
+			index, size := -1, 0
+			switch {
+			case ch == '?':
+				index, size = n, 1
+				n++
+			case ch == '$':
+				index, size = interpolatePlaceholder(query[i:], "$")
+			case ch == '@':
+				index, size = interpolatePlaceholder(query[i:], "@p")
+			case ch == ':' && (i == 0 || query[i-1] != ':'):
+				index, size = interpolatePlaceholder(query[i:], ":arg")
+			}
+			if size > 0 && index >= 0 && index < len(values) {
+				output.WriteString(interpolateValue(dialect, values[index]))
+				i += size - 1
+				continue
+			}
+		}
+		output.WriteByte(ch)
+	}
+	return output.String()
+}
+
+// interpolatePlaceholder parses a numbered placeholder such as $1, @p1 or :arg1 at the beginning of s,
+// and returns its zero-based index and its length, or a zero length if s does not start with one.
+func interpolatePlaceholder(s string, prefix string) (index int, size int) {
+	if !strings.HasPrefix(s, prefix) {
+		return -1, 0
+	}
+	size = len(prefix)
+	for size < len(s) && '0' <= s[size] && s[size] <= '9' {
+		size++
+	}
+	num, err := strconv.Atoi(s[len(prefix):size])
+	if err != nil {
+		return -1, 0
+	}
+	return num - 1, size
+}
+
+func interpolateArgs(args any) []any {
+	switch v := args.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	case []byte:
+		return []any{v}
+	}
+	if rv := reflect.ValueOf(args); rv.Kind() == reflect.Slice {
+		values := make([]any, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+		}
+		return values
+	}
+	return []any{args}
+}
+
+func interpolateValue(dialect string, value any) string {
+	if valuer, ok := value.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return "NULL"
+		}
+		v, err := valuer.Value()
+		if err != nil {
+			return "NULL"
+		}
+		value = v
+	}
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return interpolateString(dialect, v)
+	case []byte:
+		if v == nil {
+			return "NULL"
+		}
+		return interpolateBytes(dialect, v)
+	case time.Time:
+		return interpolateTime(dialect, v)
+	case bool:
+		if dialect == DialectPostgres {
+			return strings.ToUpper(strconv.FormatBool(v))
+		}
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return interpolateValue(dialect, rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits())
+	case reflect.String:
+		return interpolateString(dialect, rv.String())
+	case reflect.Bool:
+		return interpolateValue(dialect, rv.Bool())
+	default:
+		return interpolateString(dialect, fmt.Sprint(value))
+	}
+}
+
+func interpolateString(dialect string, s string) string {
+	s = strings.ReplaceAll(s, "'", "''")
+	if dialect == DialectMySQL {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	if dialect == DialectSQLServer {
+		return "N'" + s + "'"
+	}
+	return "'" + s + "'"
+}
+
+func interpolateBytes(dialect string, b []byte) string {
+	encoded := hex.EncodeToString(b)
+	switch dialect {
+	case DialectPostgres:
+		return `'\x` + encoded + "'"
+	case DialectSQLServer:
+		return "0x" + encoded
+	case DialectOracle:
+		return "HEXTORAW('" + encoded + "')"
+	default:
+		return "X'" + encoded + "'"
+	}
+}
+
+func interpolateTime(dialect string, t time.Time) string {
+	switch dialect {
+	case DialectPostgres:
+		return "'" + t.Format("2006-01-02 15:04:05.999999Z07:00") + "'"
+	case DialectMySQL:
+		return "'" + t.Format("2006-01-02 15:04:05.999999") + "'"
+	case DialectSQLServer:
+		return "'" + t.Format("2006-01-02T15:04:05.9999999Z07:00") + "'"
+	case DialectOracle:
+		return "TIMESTAMP '" + t.Format("2006-01-02 15:04:05.999999999") + "'"
+	default:
+		return "'" + t.Format("2006-01-02 15:04:05.999999999Z07:00") + "'"
+	}
+}
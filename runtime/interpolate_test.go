@@ -0,0 +1,130 @@
+package defc
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestInterpolate(t *testing.T) {
+	type TestCase struct {
+		Name   string
+		Driver string
+		Query  string
+		Args   any
+		Expect string
+	}
+	var (
+		now  = time.Date(2024, 5, 7, 12, 30, 45, 123000000, time.UTC)
+		null *int
+		age  = 18
+	)
+	var testcases = []*TestCase{
+		{
+			Name:   "question",
+			Driver: "sqlite3",
+			Query:  "SELECT * FROM user WHERE name = ? AND note = '?' AND age > ?;",
+			Args:   []any{"O'Neil", 18},
+			Expect: "SELECT * FROM user WHERE name = 'O''Neil' AND note = '?' AND age > 18;",
+		},
+		{
+			Name:   "dollar",
+			Driver: "postgres",
+			Query:  "UPDATE user SET data = $2, active = $3, updated_at = $4 WHERE id = $1::bigint",
+			Args:   []any{int64(1), []byte{0xde, 0xad}, true, now},
+			Expect: `UPDATE user SET data = '\xdead', active = TRUE, updated_at = '2024-05-07 12:30:45.123Z' WHERE id = 1::bigint`,
+		},
+		{
+			Name:   "mysql",
+			Driver: "mysql",
+			Query:  "INSERT INTO user (name, data, active, created_at) VALUES (?, ?, ?, ?)",
+			Args:   []any{`a\b`, []byte("ab"), false, now},
+			Expect: `INSERT INTO user (name, data, active, created_at) VALUES ('a\\b', X'6162', 0, '2024-05-07 12:30:45.123')`,
+		},
+		{
+			Name:   "at",
+			Driver: "sqlserver",
+			Query:  "SELECT * FROM user WHERE name = @p1 AND age = @p2",
+			Args:   []any{"defc", &age},
+			Expect: "SELECT * FROM user WHERE name = N'defc' AND age = 18",
+		},
+		{
+			Name:   "named",
+			Driver: "godror",
+			Query:  "SELECT * FROM user WHERE id = :arg1 AND age = :arg2",
+			Args:   []any{1.5, null},
+			Expect: "SELECT * FROM user WHERE id = 1.5 AND age = NULL",
+		},
+		{
+			Name:   "valuer",
+			Driver: "sqlite3",
+			Query:  "SELECT ?, ?",
+			Args:   []any{sql.NullString{String: "valid", Valid: true}, sql.NullInt64{}},
+			Expect: "SELECT 'valid', NULL",
+		},
+		{
+			Name:   "missing_args",
+			Driver: "sqlite3",
+			Query:  "SELECT ?, ?",
+			Args:   []int{1},
+			Expect: "SELECT 1, ?",
+		},
+		{
+			Name:   "extra_args",
+			Driver: "sqlite3",
+			Query:  "SELECT ?",
+			Args:   []any{1, 2},
+			Expect: "SELECT 1",
+		},
+		{
+			Name:   "quoted",
+			Driver: "postgres",
+			Query:  `SELECT "?" AS "$1", '$1 @p1 :arg1', ` + "`?`" + ` FROM user WHERE note = 'it''s ?' AND id = $1`,
+			Args:   []any{1},
+			Expect: `SELECT "?" AS "$1", '$1 @p1 :arg1', ` + "`?`" + ` FROM user WHERE note = 'it''s ?' AND id = 1`,
+		},
+		{
+			Name:   "comments",
+			Driver: "sqlite3",
+			Query:  "SELECT 1 -- x?\nFROM t WHERE a = ? /* ? */ AND b = ?",
+			Args:   []any{1, 2},
+			Expect: "SELECT 1 -- x?\nFROM t WHERE a = 1 /* ? */ AND b = 2",
+		},
+		{
+			Name:   "unterminated_comment",
+			Driver: "sqlite3",
+			Query:  "SELECT ? /* ?",
+			Args:   []any{1, 2},
+			Expect: "SELECT 1 /* ?",
+		},
+		{
+			Name:   "multiline",
+			Driver: "sqlite3",
+			Query:  "SELECT *\n\tFROM user\r\n\tWHERE name = ?\n\t  AND age = ?;\n",
+			Args:   []any{"defc", 18},
+			Expect: "SELECT *\n\tFROM user\r\n\tWHERE name = 'defc'\n\t  AND age = 18;\n",
+		},
+		{
+			Name:   "cast",
+			Driver: "postgres",
+			Query:  "SELECT $1::text, $10",
+			Args:   []any{"a"},
+			Expect: "SELECT 'a'::text, $10",
+		},
+		{
+			Name:   "single_arg",
+			Driver: "sqlite3",
+			Query:  "SELECT ?",
+			Args:   "single",
+			Expect: "SELECT 'single'",
+		},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.Name, func(t *testing.T) {
+			if output := Interpolate(testcase.Driver, testcase.Query, testcase.Args); output != testcase.Expect {
+				t.Errorf("interpolate: %q != %q", output, testcase.Expect)
+				return
+			}
+		})
+	}
+}
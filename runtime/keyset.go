@@ -0,0 +1,259 @@
+package defc
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/x5iu/defc/sqlx"
+	"github.com/x5iu/defc/sqlx/reflectx"
+)
+
+// rowValueDialects are the dialects supporting row value comparisons such as "(a, b) > (?, ?)", the condition is
+// expanded to "a > ? OR (a = ? AND b > ?)" for other dialects.
+var rowValueDialects = map[string]bool{
+	DialectPostgres: true,
+	DialectMySQL:    true,
+	DialectSQLite:   true,
+}
+
+// fetchDialects are the dialects limiting rows with "OFFSET 0 ROWS FETCH NEXT ? ROWS ONLY" instead of "LIMIT ?".
+var fetchDialects = map[string]bool{
+	DialectSQLServer: true,
+	DialectOracle:    true,
+}
+
+var ErrInvalidCursor = errors.New("defc: invalid cursor")
+
+type keysetColumn struct {
+	name string
+	desc bool
+}
+
+func parseKeysetColumns(columns []string) ([]keysetColumn, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("defc: keyset pagination requires at least one column")
+	}
+	parsed := make([]keysetColumn, 0, len(columns))
+	for _, column := range columns {
+		fields := strings.Fields(column)
+		switch {
+		case len(fields) == 1:
+			parsed = append(parsed, keysetColumn{name: fields[0]})
+		case len(fields) == 2 && (strings.EqualFold(fields[1], "ASC") || strings.EqualFold(fields[1], "DESC")):
+			parsed = append(parsed, keysetColumn{name: fields[0], desc: strings.EqualFold(fields[1], "DESC")})
+		default:
+			return nil, fmt.Errorf("defc: invalid keyset column %q", column)
+		}
+	}
+	return parsed, nil
+}
+
+// Keyset wraps query, which must be a single SELECT statement without ORDER BY, into a statement returning the
+// limit+1 rows following cursor in the order of columns, such as "created_at DESC" or "id"; the extra row tells
+// whether there is a next page. columns must be output columns of query, and an empty cursor starts from the first
+// row. The returned arguments follow the arguments of query, and the statement uses '?' bindvars like query does.
+func Keyset(driverName string, query string, cursor string, limit int, columns ...string) (string, []any, error) {
+	keys, err := parseKeysetColumns(columns)
+	if err != nil {
+		return "", nil, err
+	}
+	if limit <= 0 {
+		return "", nil, fmt.Errorf("defc: invalid page size %d", limit)
+	}
+	var values []any
+	if cursor != "" {
+		if values, err = decodeCursor(cursor); err != nil {
+			return "", nil, err
+		}
+		if len(values) != len(keys) {
+			return "", nil, fmt.Errorf("%w: %d values for %d columns", ErrInvalidCursor, len(values), len(keys))
+		}
+	}
+	var (
+		dialect = Dialect(driverName)
+		output  strings.Builder
+		args    = make([]any, 0, len(keys)*2+1)
+	)
+	output.WriteString("SELECT * FROM (")
+	output.WriteString(strings.TrimRight(strings.TrimSpace(query), "; \t\r\n"))
+	output.WriteString(") defc_keyset")
+	if values != nil {
+		output.WriteString(" WHERE ")
+		if sameDirection(keys) && rowValueDialects[dialect] {
+			names := make([]string, len(keys))
+			for i, key := range keys {
+				names[i] = key.name
+			}
+			output.WriteString("(" + strings.Join(names, ", ") + ")")
+			output.WriteString(keysetOperator(keys[0]))
+			output.WriteString("(" + BindVars(len(values)) + ")")
+			args = append(args, values...)
+		} else {
+			// a > ? OR (a = ? AND b > ?) OR (a = ? AND b = ? AND c > ?)
+			for i, key := range keys {
+				if i > 0 {
+					output.WriteString(" OR ")
+				}
+				output.WriteString("(")
+				for j := 0; j < i; j++ {
+					output.WriteString(keys[j].name + " = ? AND ")
+					args = append(args, values[j])
+				}
+				output.WriteString(key.name + keysetOperator(key) + "?)")
+				args = append(args, values[i])
+			}
+		}
+	}
+	output.WriteString(" ORDER BY ")
+	for i, key := range keys {
+		if i > 0 {
+			output.WriteString(", ")
+		}
+		output.WriteString(key.name)
+		if key.desc {
+			output.WriteString(" DESC")
+		}
+	}
+	if fetchDialects[dialect] {
+		output.WriteString(" OFFSET 0 ROWS FETCH NEXT ? ROWS ONLY")
+	} else {
+		output.WriteString(" LIMIT ?")
+	}
+	args = append(args, limit+1)
+	return output.String(), args, nil
+}
+
+func sameDirection(keys []keysetColumn) bool {
+	for _, key := range keys[1:] {
+		if key.desc != keys[0].desc {
+			return false
+		}
+	}
+	return true
+}
+
+func keysetOperator(key keysetColumn) string {
+	if key.desc {
+		return " < "
+	}
+	return " > "
+}
+
+var (
+	keysetMapper     *reflectx.Mapper
+	keysetMapperOnce sync.Once
+)
+
+// KeysetCursor returns the cursor of the page following row, which is the last row of the current page, a struct
+// whose fields are mapped to columns like sqlx does, or a map[string]any keyed by column names.
+func KeysetCursor(row any, columns ...string) (string, error) {
+	keys, err := parseKeysetColumns(columns)
+	if err != nil {
+		return "", err
+	}
+	value := reflect.ValueOf(row)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", errors.New("defc: unable to read keyset columns from nil row")
+		}
+		value = value.Elem()
+	}
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		var field reflect.Value
+		switch value.Kind() {
+		case reflect.Map:
+			if value.Type().Key().Kind() == reflect.String {
+				field = value.MapIndex(reflect.ValueOf(key.name).Convert(value.Type().Key()))
+			}
+		case reflect.Struct:
+			keysetMapperOnce.Do(func() { keysetMapper = reflectx.NewMapperFunc("db", sqlx.NameMapper) })
+			field = keysetMapper.FieldByName(value, key.name)
+		}
+		if !field.IsValid() {
+			return "", fmt.Errorf("defc: keyset column %q not found in %T", key.name, row)
+		}
+		if values[i], err = driver.DefaultParameterConverter.ConvertValue(field.Interface()); err != nil {
+			return "", fmt.Errorf("defc: keyset column %q: %w", key.name, err)
+		}
+	}
+	return encodeCursor(values)
+}
+
+// Cursor values are encoded as strings prefixed with their types, so that they are decoded as the same driver.Value
+// types: int64, float64, bool, []byte, string, time.Time or nil.
+func encodeCursor(values []any) (string, error) {
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+			encoded[i] = "n"
+		case int64:
+			encoded[i] = "i" + strconv.FormatInt(v, 10)
+		case float64:
+			encoded[i] = "f" + strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			encoded[i] = "b" + strconv.FormatBool(v)
+		case []byte:
+			encoded[i] = "x" + base64.StdEncoding.EncodeToString(v)
+		case string:
+			encoded[i] = "s" + v
+		case time.Time:
+			encoded[i] = "t" + v.Format(time.RFC3339Nano)
+		default:
+			return "", fmt.Errorf("defc: unable to encode %T in cursor", value)
+		}
+	}
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var encoded []string
+	if err = json.Unmarshal(data, &encoded); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, len(encoded))
+	for i, value := range encoded {
+		if value == "" {
+			return nil, ErrInvalidCursor
+		}
+		switch raw := value[1:]; value[0] {
+		case 'n':
+			values[i] = nil
+		case 'i':
+			values[i], err = strconv.ParseInt(raw, 10, 64)
+		case 'f':
+			values[i], err = strconv.ParseFloat(raw, 64)
+		case 'b':
+			values[i], err = strconv.ParseBool(raw)
+		case 'x':
+			values[i], err = base64.StdEncoding.DecodeString(raw)
+		case 's':
+			values[i] = raw
+		case 't':
+			values[i], err = time.Parse(time.RFC3339Nano, raw)
+		default:
+			return nil, ErrInvalidCursor
+		}
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
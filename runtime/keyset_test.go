@@ -0,0 +1,112 @@
+package defc
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeyset(t *testing.T) {
+	type Row struct {
+		ID        int64        `db:"id"`
+		CreatedAt time.Time    `db:"created_at"`
+		Name      string       `db:"name"`
+		Deleted   sql.NullBool `db:"deleted"`
+	}
+	createdAt := time.Date(2024, 5, 7, 10, 0, 0, 123, time.UTC)
+	row := &Row{ID: 42, CreatedAt: createdAt, Name: "defc", Deleted: sql.NullBool{Bool: true, Valid: true}}
+	cursor, err := KeysetCursor(row, "created_at desc", "id DESC", "name", "deleted")
+	if err != nil {
+		t.Fatalf("cursor: %s", err)
+	}
+	values, err := decodeCursor(cursor)
+	if err != nil {
+		t.Fatalf("cursor: %s", err)
+	}
+	if expect := []any{createdAt, int64(42), "defc", true}; !reflect.DeepEqual(values, expect) {
+		t.Fatalf("cursor: %v != %v", values, expect)
+	}
+	if mapCursor, err := KeysetCursor(map[string]any{"id": 42}, "id"); err != nil {
+		t.Fatalf("cursor: %s", err)
+	} else if values, _ = decodeCursor(mapCursor); !reflect.DeepEqual(values, []any{int64(42)}) {
+		t.Fatalf("cursor: %v != [42]", values)
+	}
+	if _, err = KeysetCursor(row, "missing"); err == nil {
+		t.Fatalf("cursor: expects error for missing column")
+	}
+
+	idCursor, _ := KeysetCursor(row, "created_at", "id")
+	type TestCase struct {
+		Name    string
+		Driver  string
+		Cursor  string
+		Columns []string
+		Query   string
+		Args    []any
+	}
+	testcases := []*TestCase{
+		{
+			Name:    "first_page",
+			Driver:  "sqlite3",
+			Columns: []string{"id"},
+			Query:   "SELECT * FROM (SELECT * FROM user WHERE name = ?) defc_keyset ORDER BY id LIMIT ?",
+			Args:    []any{11},
+		},
+		{
+			Name:    "row_value",
+			Driver:  "postgres",
+			Cursor:  idCursor,
+			Columns: []string{"created_at", "id"},
+			Query:   "SELECT * FROM (SELECT * FROM user WHERE name = ?) defc_keyset WHERE (created_at, id) > (?,?) ORDER BY created_at, id LIMIT ?",
+			Args:    []any{createdAt, int64(42), 11},
+		},
+		{
+			Name:    "mixed_direction",
+			Driver:  "mysql",
+			Cursor:  idCursor,
+			Columns: []string{"created_at DESC", "id"},
+			Query:   "SELECT * FROM (SELECT * FROM user WHERE name = ?) defc_keyset WHERE (created_at < ?) OR (created_at = ? AND id > ?) ORDER BY created_at DESC, id LIMIT ?",
+			Args:    []any{createdAt, createdAt, int64(42), 11},
+		},
+		{
+			Name:    "expanded",
+			Driver:  "sqlserver",
+			Cursor:  idCursor,
+			Columns: []string{"created_at desc", "id desc"},
+			Query:   "SELECT * FROM (SELECT * FROM user WHERE name = ?) defc_keyset WHERE (created_at < ?) OR (created_at = ? AND id < ?) ORDER BY created_at DESC, id DESC OFFSET 0 ROWS FETCH NEXT ? ROWS ONLY",
+			Args:    []any{createdAt, createdAt, int64(42), 11},
+		},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.Name, func(t *testing.T) {
+			query, args, err := Keyset(testcase.Driver, "SELECT * FROM user WHERE name = ?;", testcase.Cursor, 10, testcase.Columns...)
+			if err != nil {
+				t.Errorf("keyset: %s", err)
+				return
+			}
+			if query != testcase.Query {
+				t.Errorf("keyset: %q != %q", query, testcase.Query)
+				return
+			}
+			if !reflect.DeepEqual(args, testcase.Args) {
+				t.Errorf("keyset: %v != %v", args, testcase.Args)
+				return
+			}
+		})
+	}
+
+	if _, _, err = Keyset("sqlite3", "SELECT 1", "!", 10, "id"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("keyset: expects ErrInvalidCursor, got %v", err)
+	}
+	if _, _, err = Keyset("sqlite3", "SELECT 1", idCursor, 10, "id"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("keyset: expects ErrInvalidCursor, got %v", err)
+	}
+	if _, _, err = Keyset("sqlite3", "SELECT 1", "", 0, "id"); err == nil {
+		t.Errorf("keyset: expects error for invalid page size")
+	}
+	if _, _, err = Keyset("sqlite3", "SELECT 1", "", 10, "id sideways"); err == nil {
+		t.Errorf("keyset: expects error for invalid column")
+	}
+}
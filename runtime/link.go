@@ -0,0 +1,90 @@
+package defc
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ParseLink parses the RFC 5988 Link headers of header into a map from each relation type to the URL of its link,
+// such as `<https://api.example.com/users?page=2>; rel="next"`. Links with several relation types are mapped by each of
+// them, and the first link of a relation type wins.
+func ParseLink(header http.Header) map[string]string {
+	links := make(map[string]string)
+	for _, value := range header.Values("Link") {
+		for value = strings.TrimSpace(value); strings.HasPrefix(value, "<"); value = strings.TrimSpace(value) {
+			end := strings.IndexByte(value, '>')
+			if end < 0 {
+				break
+			}
+			target := value[1:end]
+			value = value[end+1:]
+			var rels []string
+			// parameters of a link run until the next comma which is not quoted
+			for {
+				value = strings.TrimLeft(value, " \t")
+				if !strings.HasPrefix(value, ";") {
+					break
+				}
+				var param string
+				param, value = cutParam(value[1:])
+				key, val, _ := strings.Cut(param, "=")
+				if strings.EqualFold(strings.TrimSpace(key), "rel") {
+					rels = strings.Fields(strings.Trim(strings.TrimSpace(val), `"`))
+				}
+			}
+			for _, rel := range rels {
+				rel = strings.ToLower(rel)
+				if _, exists := links[rel]; !exists {
+					links[rel] = target
+				}
+			}
+			if value = strings.TrimLeft(value, " \t"); strings.HasPrefix(value, ",") {
+				value = value[1:]
+			}
+		}
+	}
+	return links
+}
+
+// cutParam cuts a link parameter from s, up to the next semicolon or comma outside of quotes.
+func cutParam(s string) (param string, rest string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && quoted:
+			i++
+		case (c == ';' || c == ',') && !quoted:
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+// NextLink returns the URL of the link of relation type "next" in the Link headers of response, resolved against the
+// URL of its request, so that a response handler with the api/page feature can return it from NextPage:
+//
+//	func (r *Response) FromResponse(_ string, response *http.Response) error {
+//		r.next, r.hasNext = defc.NextLink(response)
+//		...
+//	}
+//
+//	func (r *Response) NextPage() (map[string]any, bool) {
+//		return map[string]any{"url": r.next}, r.hasNext
+//	}
+func NextLink(response *http.Response) (string, bool) {
+	next, ok := ParseLink(response.Header)["next"]
+	if !ok {
+		return "", false
+	}
+	if response.Request != nil && response.Request.URL != nil {
+		ref, err := url.Parse(next)
+		if err != nil {
+			return "", false
+		}
+		next = response.Request.URL.ResolveReference(ref).String()
+	}
+	return next, true
+}
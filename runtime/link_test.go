@@ -0,0 +1,43 @@
+package defc
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseLink(t *testing.T) {
+	header := make(http.Header)
+	header.Add("Link", `<https://api.example.com/users?page=2&fields=a,b>; rel="next", `+
+		`<https://api.example.com/users?page=9>; title="last; page"; rel="last"`)
+	header.Add("Link", `<https://api.example.com/users?page=1>; rel="first prev"`)
+	header.Add("Link", `<https://api.example.com/other>; rel=next`)
+	expects := map[string]string{
+		"next":  "https://api.example.com/users?page=2&fields=a,b",
+		"last":  "https://api.example.com/users?page=9",
+		"first": "https://api.example.com/users?page=1",
+		"prev":  "https://api.example.com/users?page=1",
+	}
+	if links := ParseLink(header); !reflect.DeepEqual(links, expects) {
+		t.Errorf("link: %v != %v", links, expects)
+		return
+	}
+	header.Set("Link", `https://api.example.com/users; rel="next"`)
+	if links := ParseLink(header); len(links) != 0 {
+		t.Errorf("link: expects no links, got %v", links)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	requestURL, _ := url.Parse("https://api.example.com/v1/users?page=1")
+	response := &http.Response{Header: make(http.Header), Request: &http.Request{URL: requestURL}}
+	if next, ok := NextLink(response); ok {
+		t.Errorf("link: expects no next link, got %s", next)
+		return
+	}
+	response.Header.Set("Link", `</v1/users?cursor=abc>; rel="next"`)
+	if next, ok := NextLink(response); !ok || next != "https://api.example.com/v1/users?cursor=abc" {
+		t.Errorf("link: %s (%v) != %s", next, ok, "https://api.example.com/v1/users?cursor=abc")
+	}
+}
@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/x5iu/defc/sqlx"
+)
+
+// Options are the options of Command, they are encoded as JSON when the defc CLI passes them to a program which
+// imports the database driver.
+type Options struct {
+	Driver string `json:"driver"`
+	DSN    string `json:"dsn"`
+	Dir    string `json:"dir"`
+	Table  string `json:"table"`
+}
+
+// Command runs one of the "up", "down [N]" and "status" subcommands of `defc migrate` and writes its report to w.
+func Command(ctx context.Context, w io.Writer, opts Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate: expects a subcommand: up, down [N] or status")
+	}
+	db, err := sqlx.Open(opts.Driver, opts.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := New(db, os.DirFS(opts.Dir), ".")
+	if err != nil {
+		return err
+	}
+	migrator.Table = opts.Table
+	switch subcommand := args[0]; subcommand {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(w, "applied %s\n", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+		return err
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("migrate: invalid number of migrations %q", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, n)
+		for _, migration := range rolledBack {
+			fmt.Fprintf(w, "rolled back %s\n", migration)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			var (
+				version, name = statusName(status)
+				state         = "pending"
+				appliedAt     = "-"
+			)
+			if status.Applied() {
+				state, appliedAt = "applied", status.Record.AppliedAt.Local().Format(time.RFC3339)
+			}
+			if status.Missing() {
+				state = "missing"
+			} else if status.Modified() {
+				state = "modified"
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", version, name, state, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %q, available subcommands are: up, down [N], status", subcommand)
+	}
+}
+
+func statusName(status *Status) (int64, string) {
+	if status.Migration != nil {
+		return status.Migration.Version, status.Migration.Name
+	}
+	return status.Record.Version, status.Record.Name
+}
@@ -0,0 +1,437 @@
+// Package migrate applies versioned SQL migrations to a database and records them in a version table.
+//
+// Migrations are pairs of files named NNNN_name.up.sql and NNNN_name.down.sql, where NNNN is the version number;
+// the down file is optional, but a migration without one cannot be rolled back. Statements of a file are split by
+// ';' with runtime.Split, and each migration runs in its own transaction together with the update of the version
+// table, except for dialects whose DDL statements commit implicitly (MySQL and Oracle), or migrations whose first
+// line is the directive:
+//
+//	-- defc:no-transaction
+//
+// The checksum of each up file is recorded when it is applied, so that a migration edited afterwards is reported by
+// Status and refused by Up and Down. The version table is created by Up, the other methods never write to a database
+// without migrations.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	__rt "github.com/x5iu/defc/runtime"
+	"github.com/x5iu/defc/sqlx"
+)
+
+const (
+	// DefaultTable is the name of the version table when Migrator.Table is empty.
+	DefaultTable = "schema_migrations"
+
+	// NoTransaction is the directive which disables the transaction of a migration.
+	NoTransaction = "-- defc:no-transaction"
+)
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a versioned migration read from the files of a directory.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// HasDown reports whether the migration has a down file.
+func (migration *Migration) HasDown() bool {
+	return strings.TrimSpace(migration.Down) != ""
+}
+
+func (migration *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", migration.Version, migration.Name)
+}
+
+// Load reads the migrations of dir in fsys, sorted by version.
+func Load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]*Migration, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := migrationFile.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: invalid version of %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := versions[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			versions[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %q and %q", version, migration.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+			migration.Checksum = Checksum(migration.Up)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+	migrations := make([]*Migration, 0, len(versions))
+	for _, migration := range versions {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("migrate: migration %s has no up file", migration)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Checksum returns the checksum recorded for the content of an up file.
+func Checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create writes the up and down files of a new migration named name in dir, whose version follows the latest one,
+// and returns their paths.
+func Create(dir string, name string) (up string, down string, err error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migrate: empty migration name")
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	migrations, err := Load(os.DirFS(dir), ".")
+	if err != nil {
+		return "", "", err
+	}
+	var version int64 = 1
+	if n := len(migrations); n > 0 {
+		version = migrations[n-1].Version + 1
+	}
+	prefix := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	up, down = prefix+".up.sql", prefix+".down.sql"
+	if err = os.WriteFile(up, []byte(fmt.Sprintf("-- %04d_%s: up\n", version, name)), 0644); err != nil {
+		return "", "", err
+	}
+	if err = os.WriteFile(down, []byte(fmt.Sprintf("-- %04d_%s: down\n", version, name)), 0644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// Record is a row of the version table.
+type Record struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Status is the status of a migration, Migration is nil when an applied migration has no file anymore,
+// and Record is nil when the migration is pending.
+type Status struct {
+	Migration *Migration
+	Record    *Record
+}
+
+func (status *Status) Applied() bool { return status.Record != nil }
+
+// Modified reports whether the up file has been edited after the migration was applied.
+func (status *Status) Modified() bool {
+	return status.Migration != nil && status.Record != nil && status.Migration.Checksum != status.Record.Checksum
+}
+
+// Missing reports whether the migration was applied but its files do not exist anymore.
+func (status *Status) Missing() bool { return status.Migration == nil }
+
+// Migrator applies Migrations to DB.
+type Migrator struct {
+	DB         *sqlx.DB
+	Migrations []*Migration
+	Table      string
+}
+
+// New returns a Migrator applying the migrations of dir in fsys to db.
+func New(db *sqlx.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+func (m *Migrator) table() string {
+	if m.Table == "" {
+		return DefaultTable
+	}
+	return m.Table
+}
+
+func (m *Migrator) dialect() string {
+	return __rt.Dialect(m.DB.DriverName())
+}
+
+// Records returns the rows of the version table sorted by version, or no rows if the table does not exist yet.
+func (m *Migrator) Records(ctx context.Context) ([]*Record, error) {
+	exists, err := m.tableExists(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+	var records []*Record
+	query := fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s ORDER BY version", m.table())
+	if err = m.DB.SelectContext(ctx, &records, query); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return records, nil
+}
+
+func (m *Migrator) tableExists(ctx context.Context) (bool, error) {
+	var n int
+	if err := m.DB.GetContext(ctx, &n, m.DB.Rebind(m.existsTable()), m.table()); err != nil {
+		return false, fmt.Errorf("migrate: checking version table %s: %w", m.table(), err)
+	}
+	return n > 0, nil
+}
+
+// existsTable returns the query counting the tables named by its only argument, which is 0 or 1.
+func (m *Migrator) existsTable() string {
+	switch m.dialect() {
+	case __rt.DialectPostgres:
+		return "SELECT CASE WHEN to_regclass(?) IS NULL THEN 0 ELSE 1 END"
+	case __rt.DialectMySQL:
+		return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	case __rt.DialectSQLite:
+		return "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	case __rt.DialectSQLServer:
+		return "SELECT CASE WHEN OBJECT_ID(?, 'U') IS NULL THEN 0 ELSE 1 END"
+	case __rt.DialectOracle:
+		return "SELECT COUNT(*) FROM user_tables WHERE table_name = UPPER(?)"
+	default:
+		return "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = ?"
+	}
+}
+
+// createTable creates the version table if it does not exist, a dialect-specific "IF NOT EXISTS" clause is not
+// used since it is not supported by every database.
+func (m *Migrator) createTable(ctx context.Context) error {
+	exists, err := m.tableExists(ctx)
+	if err != nil || exists {
+		return err
+	}
+	if _, err = m.DB.ExecContext(ctx, m.createTableQuery()); err != nil {
+		return fmt.Errorf("migrate: creating version table %s: %w", m.table(), err)
+	}
+	return nil
+}
+
+func (m *Migrator) createTableQuery() string {
+	versionType, textType, timeType := "BIGINT", "VARCHAR(255)", "TIMESTAMP"
+	switch m.dialect() {
+	case __rt.DialectSQLServer:
+		timeType = "DATETIME2"
+	case __rt.DialectOracle:
+		versionType, textType = "NUMBER(19)", "VARCHAR2(255)"
+	}
+	return fmt.Sprintf("CREATE TABLE %s (version %s PRIMARY KEY, name %s NOT NULL, checksum %s NOT NULL, applied_at %s NOT NULL)",
+		m.table(), versionType, textType, textType, timeType)
+}
+
+// Status returns the status of all migrations, including applied ones whose files do not exist anymore,
+// sorted by version.
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	records, err := m.Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]*Status, 0, len(m.Migrations)+len(records))
+	applied := make(map[int64]*Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	for _, migration := range m.Migrations {
+		statuses = append(statuses, &Status{Migration: migration, Record: applied[migration.Version]})
+		delete(applied, migration.Version)
+	}
+	for _, record := range records {
+		if _, missing := applied[record.Version]; missing {
+			statuses = append(statuses, &Status{Record: record})
+		}
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].version() < statuses[j].version()
+	})
+	return statuses, nil
+}
+
+func (status *Status) version() int64 {
+	if status.Migration != nil {
+		return status.Migration.Version
+	}
+	return status.Record.Version
+}
+
+// Verify returns an error if an applied migration has been edited or removed.
+func (m *Migrator) Verify(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return verify(statuses)
+}
+
+func verify(statuses []*Status) error {
+	for _, status := range statuses {
+		if status.Missing() {
+			return fmt.Errorf("migrate: applied migration %04d_%s is missing", status.Record.Version, status.Record.Name)
+		}
+		if status.Modified() {
+			return fmt.Errorf("migrate: applied migration %s has been modified (checksum %s != %s)",
+				status.Migration, status.Migration.Checksum, status.Record.Checksum)
+		}
+	}
+	return nil
+}
+
+// Up applies all pending migrations in version order, after verifying the applied ones, and returns the migrations
+// which have been applied.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = verify(statuses); err != nil {
+		return nil, err
+	}
+	applied := make([]*Migration, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Applied() {
+			continue
+		}
+		record := fmt.Sprintf("INSERT INTO %s (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)", m.table())
+		if err = m.run(ctx, status.Migration.Up, m.DB.Rebind(record),
+			status.Migration.Version, status.Migration.Name, status.Migration.Checksum, time.Now().UTC()); err != nil {
+			return applied, fmt.Errorf("migrate: up %s: %w", status.Migration, err)
+		}
+		applied = append(applied, status.Migration)
+	}
+	return applied, nil
+}
+
+// Down rolls back the latest n applied migrations in reverse version order, and returns the migrations which have
+// been rolled back. A migration whose up file has been edited since it was applied is not rolled back, since its down
+// file may not match the applied schema anymore.
+func (m *Migrator) Down(ctx context.Context, n int) ([]*Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rolledBack := make([]*Migration, 0, n)
+	for i := len(statuses) - 1; i >= 0 && len(rolledBack) < n; i-- {
+		status := statuses[i]
+		if !status.Applied() {
+			continue
+		}
+		if status.Missing() {
+			return rolledBack, fmt.Errorf("migrate: applied migration %04d_%s is missing", status.Record.Version, status.Record.Name)
+		}
+		if status.Modified() {
+			return rolledBack, fmt.Errorf("migrate: applied migration %s has been modified (checksum %s != %s)",
+				status.Migration, status.Migration.Checksum, status.Record.Checksum)
+		}
+		if !status.Migration.HasDown() {
+			return rolledBack, fmt.Errorf("migrate: migration %s has no down file", status.Migration)
+		}
+		record := fmt.Sprintf("DELETE FROM %s WHERE version = ?", m.table())
+		if err = m.run(ctx, status.Migration.Down, m.DB.Rebind(record), status.Migration.Version); err != nil {
+			return rolledBack, fmt.Errorf("migrate: down %s: %w", status.Migration, err)
+		}
+		rolledBack = append(rolledBack, status.Migration)
+	}
+	return rolledBack, nil
+}
+
+func (m *Migrator) transactional(script string) bool {
+	if strings.HasPrefix(strings.TrimSpace(script), NoTransaction) {
+		return false
+	}
+	switch m.dialect() {
+	case __rt.DialectMySQL, __rt.DialectOracle:
+		return false
+	default:
+		return true
+	}
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// run executes the statements of script followed by the record statement, in a transaction if possible.
+func (m *Migrator) run(ctx context.Context, script string, record string, args ...any) (err error) {
+	var conn execer = m.DB
+	if m.transactional(script) {
+		tx, beginErr := m.DB.BeginTxx(ctx, nil)
+		if beginErr != nil {
+			return beginErr
+		}
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+			} else {
+				err = tx.Commit()
+			}
+		}()
+		conn = tx
+	}
+	for _, stmt := range __rt.Split(script, ";") {
+		if strings.TrimSpace(strings.Trim(strings.TrimSpace(stmt), ";")) == "" || isComment(stmt) {
+			continue
+		}
+		if _, err = conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\n\n%s", err, strings.TrimSpace(stmt))
+		}
+	}
+	_, err = conn.ExecContext(ctx, record, args...)
+	return err
+}
+
+// isComment reports whether stmt only consists of line comments, such as the header written by Create.
+func isComment(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		if line = strings.TrimSpace(line); line != "" && line != ";" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
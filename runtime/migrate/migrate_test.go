@@ -0,0 +1,270 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/x5iu/defc/runtime/sqlxtest"
+)
+
+var testFS = fstest.MapFS{
+	"migrations/0002_add_index.up.sql":      {Data: []byte("CREATE INDEX users_name ON users (name);")},
+	"migrations/0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER, name TEXT DEFAULT 'a;b');\nINSERT INTO users (name) VALUES ('defc');\n")},
+	"migrations/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"migrations/README.md":                  {Data: []byte("migrations")},
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFS, "migrations")
+	if err != nil {
+		t.Fatalf("load: %s", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("load: %d migrations != 2", len(migrations))
+	}
+	if first := migrations[0]; first.String() != "0001_create_users" || !first.HasDown() ||
+		first.Checksum != Checksum(string(testFS["migrations/0001_create_users.up.sql"].Data)) {
+		t.Errorf("load: unexpected %+v", first)
+	}
+	if second := migrations[1]; second.String() != "0002_add_index" || second.HasDown() {
+		t.Errorf("load: unexpected %+v", second)
+	}
+	for _, fsys := range []fstest.MapFS{
+		{"m/0001_a.down.sql": {Data: []byte("DROP TABLE a;")}},
+		{"m/0001_a.up.sql": {Data: []byte("")}, "m/0001_b.up.sql": {Data: []byte("")}},
+	} {
+		if _, err = Load(fsys, "m"); err == nil {
+			t.Errorf("load: expects error for %v", fsys)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+	up, down, err := Create(dir, "Create Users!")
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	if filepath.Base(up) != "0001_create_users.up.sql" || filepath.Base(down) != "0001_create_users.down.sql" {
+		t.Errorf("create: unexpected %s, %s", up, down)
+	}
+	if up, _, err = Create(dir, "add-index"); err != nil || filepath.Base(up) != "0002_add_index.up.sql" {
+		t.Errorf("create: unexpected %s (%v)", up, err)
+	}
+	migrations, err := Load(os.DirFS(dir), ".")
+	if err != nil || len(migrations) != 2 {
+		t.Fatalf("create: unexpected %v (%v)", migrations, err)
+	}
+	if _, _, err = Create(dir, "!!!"); err == nil {
+		t.Errorf("create: expects error for empty name")
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	const selectRecords = "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version"
+	newMigrator := func(t *testing.T, driverName string) (*Migrator, *sqlxtest.Core) {
+		core := sqlxtest.New(driverName)
+		t.Cleanup(func() { core.Close() })
+		migrator, err := New(core.DB, testFS, "migrations")
+		if err != nil {
+			t.Fatalf("new: %s", err)
+		}
+		return migrator, core
+	}
+	expectTable := func(migrator *Migrator, core *sqlxtest.Core, exists bool) {
+		n := 0
+		if exists {
+			n = 1
+		}
+		core.ExpectQuery(core.Rebind(migrator.existsTable())).
+			WithArgs("schema_migrations").
+			WillReturnRows(sqlxtest.NewRows("n").AddRow(n))
+	}
+	t.Run("up", func(t *testing.T) {
+		migrator, core := newMigrator(t, "sqlite3")
+		expectTable(migrator, core, false)
+		core.ExpectExec(migrator.createTableQuery())
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).WillReturnRows(sqlxtest.NewRows("version", "name", "checksum", "applied_at"))
+		core.ExpectExec("CREATE TABLE users (id INTEGER, name TEXT DEFAULT 'a;b');")
+		core.ExpectExec("INSERT INTO users (name) VALUES ('defc');")
+		core.ExpectExec("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)").
+			WithArgs(1, "create_users", migrator.Migrations[0].Checksum, sqlxtest.AnyArg())
+		core.ExpectExec("CREATE INDEX users_name ON users (name);")
+		core.ExpectExec("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)").
+			WithArgs(2, "add_index", migrator.Migrations[1].Checksum, sqlxtest.AnyArg())
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			t.Fatalf("up: %s", err)
+		}
+		if len(applied) != 2 {
+			t.Errorf("up: %d applied != 2", len(applied))
+		}
+		if err = core.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		if begins, commits, rollbacks := core.Transactions(); begins != 2 || commits != 2 || rollbacks != 0 {
+			t.Errorf("up: transactions (%d, %d, %d) != (2, 2, 0)", begins, commits, rollbacks)
+		}
+	})
+	t.Run("up_without_transaction", func(t *testing.T) {
+		migrator, core := newMigrator(t, "mysql")
+		expectTable(migrator, core, true)
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).
+			WillReturnRows(sqlxtest.NewRows("version", "name", "checksum", "applied_at").
+				AddRow(1, "create_users", migrator.Migrations[0].Checksum, time.Now()))
+		core.ExpectExec("CREATE INDEX users_name ON users (name);")
+		core.ExpectExec("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)")
+		if _, err := migrator.Up(ctx); err != nil {
+			t.Fatalf("up: %s", err)
+		}
+		if begins, _, _ := core.Transactions(); begins != 0 {
+			t.Errorf("up: %d transactions begun for mysql", begins)
+		}
+	})
+	t.Run("up_modified", func(t *testing.T) {
+		migrator, core := newMigrator(t, "sqlite3")
+		expectTable(migrator, core, true)
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).
+			WillReturnRows(sqlxtest.NewRows("version", "name", "checksum", "applied_at").
+				AddRow(1, "create_users", Checksum("CREATE TABLE users (id INTEGER);"), time.Now()))
+		if _, err := migrator.Up(ctx); err == nil || !strings.Contains(err.Error(), "has been modified") {
+			t.Errorf("up: expects modified error, got %v", err)
+		}
+	})
+	t.Run("up_failed", func(t *testing.T) {
+		migrator, core := newMigrator(t, "postgres")
+		expectTable(migrator, core, true)
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).WillReturnRows(sqlxtest.NewRows("version", "name", "checksum", "applied_at"))
+		core.ExpectExec("CREATE TABLE users (id INTEGER, name TEXT DEFAULT 'a;b');").WillReturnError(errors.New("syntax error"))
+		if _, err := migrator.Up(ctx); err == nil || !strings.Contains(err.Error(), "up 0001_create_users: syntax error") {
+			t.Errorf("up: expects syntax error, got %v", err)
+		}
+		if _, _, rollbacks := core.Transactions(); rollbacks != 1 {
+			t.Errorf("up: %d rollbacks != 1", rollbacks)
+		}
+	})
+	t.Run("down", func(t *testing.T) {
+		migrator, core := newMigrator(t, "postgres")
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).
+			WillReturnRows(sqlxtest.NewRows("version", "name", "checksum", "applied_at").
+				AddRow(1, "create_users", migrator.Migrations[0].Checksum, time.Now()))
+		core.ExpectExec("DROP TABLE users;")
+		core.ExpectExec("DELETE FROM schema_migrations WHERE version = $1").WithArgs(1)
+		rolledBack, err := migrator.Down(ctx, 2)
+		if err != nil {
+			t.Fatalf("down: %s", err)
+		}
+		if len(rolledBack) != 1 {
+			t.Errorf("down: %d rolled back != 1", len(rolledBack))
+		}
+		if err = core.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("down_without_file", func(t *testing.T) {
+		migrator, core := newMigrator(t, "postgres")
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).
+			WillReturnRows(sqlxtest.NewRows("version", "name", "checksum", "applied_at").
+				AddRow(1, "create_users", migrator.Migrations[0].Checksum, time.Now()).
+				AddRow(2, "add_index", migrator.Migrations[1].Checksum, time.Now()))
+		if _, err := migrator.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "has no down file") {
+			t.Errorf("down: expects no down file error, got %v", err)
+		}
+	})
+	t.Run("down_modified", func(t *testing.T) {
+		migrator, core := newMigrator(t, "postgres")
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).
+			WillReturnRows(sqlxtest.NewRows("version", "name", "checksum", "applied_at").
+				AddRow(1, "create_users", Checksum("CREATE TABLE users (id INTEGER);"), time.Now()))
+		if _, err := migrator.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "has been modified") {
+			t.Errorf("down: expects modified error, got %v", err)
+		}
+		if statements := core.Statements(); len(statements) != 2 {
+			t.Errorf("down: %d statements executed, expects only the checks", len(statements))
+		}
+	})
+	t.Run("status_without_table", func(t *testing.T) {
+		migrator, core := newMigrator(t, "sqlite3")
+		expectTable(migrator, core, false)
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			t.Fatalf("status: %s", err)
+		}
+		if len(statuses) != 2 || statuses[0].Applied() || statuses[1].Applied() {
+			t.Errorf("status: expects 2 pending migrations, got %v", statuses)
+		}
+		if statements := core.Statements(); len(statements) != 1 {
+			t.Errorf("status: %d statements executed, expects only the check", len(statements))
+		}
+	})
+	t.Run("status_failed", func(t *testing.T) {
+		migrator, core := newMigrator(t, "sqlite3")
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).WillReturnError(errors.New("permission denied"))
+		if _, err := migrator.Status(ctx); err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Errorf("status: expects permission error, got %v", err)
+		}
+	})
+	t.Run("up_failed_records", func(t *testing.T) {
+		migrator, core := newMigrator(t, "sqlite3")
+		expectTable(migrator, core, true)
+		expectTable(migrator, core, true)
+		core.ExpectQuery(selectRecords).WillReturnError(errors.New("permission denied"))
+		if _, err := migrator.Up(ctx); err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Errorf("up: expects permission error, got %v", err)
+		}
+		if err := core.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		if statements := core.Statements(); len(statements) != 3 {
+			t.Errorf("up: %d statements executed, expects no CREATE TABLE", len(statements))
+		}
+	})
+	t.Run("status", func(t *testing.T) {
+		migrator, core := newMigrator(t, "sqlite3")
+		expectTable(migrator, core, true)
+		appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		core.ExpectQuery(selectRecords).
+			WillReturnRows(sqlxtest.NewRows("version", "name", "checksum", "applied_at").
+				AddRow(1, "create_users", "edited", appliedAt).
+				AddRow(3, "removed", "checksum", appliedAt))
+		var output bytes.Buffer
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			t.Fatalf("status: %s", err)
+		}
+		for _, status := range statuses {
+			version, name := statusName(status)
+			output.WriteString(name)
+			switch {
+			case status.Missing():
+				output.WriteString(" missing")
+			case status.Modified():
+				output.WriteString(" modified")
+			case status.Applied():
+				output.WriteString(" applied")
+			default:
+				output.WriteString(" pending")
+			}
+			output.WriteString(" " + time.Duration(version).String() + "\n")
+		}
+		if expect := "create_users modified 1ns\nadd_index pending 2ns\nremoved missing 3ns\n"; output.String() != expect {
+			t.Errorf("status: %q != %q", output.String(), expect)
+		}
+	})
+}
@@ -0,0 +1,374 @@
+// Package query builds SELECT statements whose filters, sorts and pagination are decided at runtime:
+//
+//	q := query.Select("id", "name").From("users").
+//		Where(
+//			query.If(name != "", query.Like("name", name+"%")),
+//			query.In("role", roles),
+//		).
+//		OrderBy("id DESC").
+//		Limit(20)
+//
+// Statements are built with '?' bindvars and runtime.Arguments, so that they can be rebound for other drivers with
+// Rebind like the queries of generated code, and methods with the BUILDER option accept them as arguments.
+package query
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	__rt "github.com/x5iu/defc/runtime"
+)
+
+// Builder is implemented by statements which build a query and its arguments, it is the type of the argument of
+// methods with the BUILDER option.
+type Builder interface {
+	Build() (string, []any, error)
+}
+
+// Cond is a condition of a WHERE clause, nil conditions are ignored.
+type Cond interface {
+	build(sql *strings.Builder, args *__rt.Arguments)
+}
+
+// SelectBuilder is a SELECT statement, its methods modify and return the statement itself.
+type SelectBuilder struct {
+	columns []string
+	from    string
+	where   []Cond
+	groupBy []string
+	having  []Cond
+	orderBy []string
+	limit   int
+	offset  int
+	dialect string
+}
+
+// Select starts a SELECT statement of columns, or of all columns when there is none.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns, limit: -1, offset: -1}
+}
+
+// From sets the table of the statement, which may also be a join expression.
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from = table
+	return b
+}
+
+// Where adds conditions which are joined by AND with the existing ones.
+func (b *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+// GroupBy adds expressions to the GROUP BY clause.
+func (b *SelectBuilder) GroupBy(exprs ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, exprs...)
+	return b
+}
+
+// Having adds conditions of the HAVING clause which are joined by AND with the existing ones.
+func (b *SelectBuilder) Having(conds ...Cond) *SelectBuilder {
+	b.having = append(b.having, conds...)
+	return b
+}
+
+// OrderBy adds expressions to the ORDER BY clause, such as "id DESC".
+func (b *SelectBuilder) OrderBy(exprs ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, exprs...)
+	return b
+}
+
+// Limit sets the maximum number of rows, a negative n removes the limit.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset sets the number of rows to skip, a negative n removes the offset.
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// Driver sets the driver name of the database, which decides how Limit and Offset are written: SQL Server and Oracle
+// use "OFFSET n ROWS FETCH NEXT n ROWS ONLY" (SQL Server requires an ORDER BY clause with it), and other dialects
+// use "LIMIT n OFFSET n", which is also the default when no driver name is set.
+func (b *SelectBuilder) Driver(driverName string) *SelectBuilder {
+	b.dialect = __rt.Dialect(driverName)
+	return b
+}
+
+// Build returns the query and its arguments.
+func (b *SelectBuilder) Build() (string, []any, error) {
+	if b.from == "" {
+		return "", nil, errors.New("query: SELECT statement without FROM clause")
+	}
+	var (
+		sql  strings.Builder
+		args = make(__rt.Arguments, 0, 8)
+	)
+	sql.WriteString("SELECT ")
+	if len(b.columns) == 0 {
+		sql.WriteString("*")
+	} else {
+		sql.WriteString(strings.Join(b.columns, ", "))
+	}
+	sql.WriteString(" FROM ")
+	sql.WriteString(b.from)
+	if cond := And(b.where...); !isEmpty(cond) {
+		sql.WriteString(" WHERE ")
+		cond.build(&sql, &args)
+	}
+	if len(b.groupBy) > 0 {
+		sql.WriteString(" GROUP BY ")
+		sql.WriteString(strings.Join(b.groupBy, ", "))
+	}
+	if cond := And(b.having...); !isEmpty(cond) {
+		sql.WriteString(" HAVING ")
+		cond.build(&sql, &args)
+	}
+	if len(b.orderBy) > 0 {
+		sql.WriteString(" ORDER BY ")
+		sql.WriteString(strings.Join(b.orderBy, ", "))
+	}
+	if b.dialect == __rt.DialectSQLServer || b.dialect == __rt.DialectOracle {
+		if b.limit >= 0 || b.offset >= 0 {
+			offset := b.offset
+			if offset < 0 {
+				offset = 0
+			}
+			sql.WriteString(" OFFSET ")
+			sql.WriteString(strconv.Itoa(offset))
+			sql.WriteString(" ROWS")
+		}
+		if b.limit >= 0 {
+			sql.WriteString(" FETCH NEXT ")
+			sql.WriteString(strconv.Itoa(b.limit))
+			sql.WriteString(" ROWS ONLY")
+		}
+		return sql.String(), args, nil
+	}
+	if b.limit >= 0 {
+		sql.WriteString(" LIMIT ")
+		sql.WriteString(strconv.Itoa(b.limit))
+	}
+	if b.offset >= 0 {
+		sql.WriteString(" OFFSET ")
+		sql.WriteString(strconv.Itoa(b.offset))
+	}
+	return sql.String(), args, nil
+}
+
+// Count returns a statement counting the rows matched by b, ignoring its columns, sorts and pagination.
+func (b *SelectBuilder) Count() *SelectBuilder {
+	return &SelectBuilder{
+		columns: []string{"COUNT(*)"},
+		from:    b.from,
+		where:   b.where,
+		groupBy: b.groupBy,
+		having:  b.having,
+		limit:   -1,
+		offset:  -1,
+	}
+}
+
+type compare struct {
+	column string
+	op     string
+	value  any
+}
+
+func (c *compare) build(sql *strings.Builder, args *__rt.Arguments) {
+	sql.WriteString(c.column)
+	sql.WriteString(" " + c.op + " ")
+	sql.WriteString(args.Bind(c.value))
+}
+
+// Eq is the condition "column = value".
+func Eq(column string, value any) Cond { return &compare{column, "=", value} }
+
+// NotEq is the condition "column <> value".
+func NotEq(column string, value any) Cond { return &compare{column, "<>", value} }
+
+// Lt is the condition "column < value".
+func Lt(column string, value any) Cond { return &compare{column, "<", value} }
+
+// Lte is the condition "column <= value".
+func Lte(column string, value any) Cond { return &compare{column, "<=", value} }
+
+// Gt is the condition "column > value".
+func Gt(column string, value any) Cond { return &compare{column, ">", value} }
+
+// Gte is the condition "column >= value".
+func Gte(column string, value any) Cond { return &compare{column, ">=", value} }
+
+// Like is the condition "column LIKE pattern".
+func Like(column string, pattern any) Cond { return &compare{column, "LIKE", pattern} }
+
+type in struct {
+	column string
+	not    bool
+	values any
+}
+
+func (c *in) build(sql *strings.Builder, args *__rt.Arguments) {
+	var values []any
+	if c.values != nil {
+		values = __rt.MergeArgs(c.values)
+	}
+	if len(values) == 0 {
+		// "IN ()" is a syntax error, an empty list matches no row, and every row for NOT IN.
+		if c.not {
+			sql.WriteString("1 = 1")
+		} else {
+			sql.WriteString("1 = 0")
+		}
+		return
+	}
+	sql.WriteString(c.column)
+	if c.not {
+		sql.WriteString(" NOT IN (")
+	} else {
+		sql.WriteString(" IN (")
+	}
+	sql.WriteString(args.Bind(values))
+	sql.WriteString(")")
+}
+
+// In is the condition "column IN (values...)", values is a slice or a runtime.ToArgs.
+func In(column string, values any) Cond { return &in{column: column, values: values} }
+
+// NotIn is the condition "column NOT IN (values...)", values is a slice or a runtime.ToArgs.
+func NotIn(column string, values any) Cond { return &in{column: column, not: true, values: values} }
+
+type isNull struct {
+	column string
+	not    bool
+}
+
+func (c *isNull) build(sql *strings.Builder, _ *__rt.Arguments) {
+	sql.WriteString(c.column)
+	if c.not {
+		sql.WriteString(" IS NOT NULL")
+	} else {
+		sql.WriteString(" IS NULL")
+	}
+}
+
+// IsNull is the condition "column IS NULL".
+func IsNull(column string) Cond { return &isNull{column: column} }
+
+// IsNotNull is the condition "column IS NOT NULL".
+func IsNotNull(column string) Cond { return &isNull{column: column, not: true} }
+
+type expr struct {
+	sql  string
+	args []any
+}
+
+func (c *expr) build(sql *strings.Builder, args *__rt.Arguments) {
+	var (
+		quote byte
+		n     int
+	)
+	for i := 0; i < len(c.sql); i++ {
+		switch ch := c.sql[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?' && n < len(c.args):
+			sql.WriteString(args.Bind(c.args[n]))
+			n++
+			continue
+		}
+		sql.WriteByte(c.sql[i])
+	}
+	for _, arg := range c.args[n:] {
+		args.Bind(arg)
+	}
+}
+
+// Expr is a raw condition whose '?' bindvars are bound to args in order. Like In, a slice argument is expanded into
+// one bindvar per element, so that "id IN (?)" works with a slice of ids; wrap a slice in a driver.Valuer (such as
+// pq.Array) to bind it as a single argument, like in "id = ANY(?)".
+func Expr(sql string, args ...any) Cond { return &expr{sql: sql, args: args} }
+
+type junction struct {
+	op    string
+	conds []Cond
+}
+
+func (c *junction) build(sql *strings.Builder, args *__rt.Arguments) {
+	n := 0
+	for _, cond := range c.conds {
+		if isEmpty(cond) {
+			continue
+		}
+		if n > 0 {
+			sql.WriteString(" " + c.op + " ")
+		}
+		_, nested := cond.(*junction)
+		if nested {
+			sql.WriteString("(")
+		}
+		cond.build(sql, args)
+		if nested {
+			sql.WriteString(")")
+		}
+		n++
+	}
+}
+
+// And joins conditions with AND, nil conditions are ignored.
+func And(conds ...Cond) Cond { return &junction{op: "AND", conds: conds} }
+
+// Or joins conditions with OR, nil conditions are ignored.
+func Or(conds ...Cond) Cond { return &junction{op: "OR", conds: conds} }
+
+type not struct {
+	cond Cond
+}
+
+func (c *not) build(sql *strings.Builder, args *__rt.Arguments) {
+	sql.WriteString("NOT (")
+	c.cond.build(sql, args)
+	sql.WriteString(")")
+}
+
+// Not negates a condition.
+func Not(cond Cond) Cond {
+	if isEmpty(cond) {
+		return nil
+	}
+	return &not{cond: cond}
+}
+
+// If returns cond when ok is true, and nil otherwise, which makes cond optional:
+//
+//	Where(query.If(name != "", query.Eq("name", name)))
+func If(ok bool, cond Cond) Cond {
+	if !ok {
+		return nil
+	}
+	return cond
+}
+
+func isEmpty(cond Cond) bool {
+	switch c := cond.(type) {
+	case nil:
+		return true
+	case *junction:
+		for _, nested := range c.conds {
+			if !isEmpty(nested) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
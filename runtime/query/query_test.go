@@ -0,0 +1,143 @@
+package query
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"testing"
+
+	__rt "github.com/x5iu/defc/runtime"
+)
+
+type int64Array []int64
+
+func (a int64Array) Value() (driver.Value, error) { return fmt.Sprint([]int64(a)), nil }
+
+func TestSelect(t *testing.T) {
+	type TestCase struct {
+		Name    string
+		Builder *SelectBuilder
+		SQL     string
+		Args    []any
+	}
+	var (
+		name  = ""
+		roles = []string{"admin", "owner"}
+	)
+	testcases := []*TestCase{
+		{
+			Name:    "all",
+			Builder: Select().From("users"),
+			SQL:     "SELECT * FROM users",
+			Args:    []any{},
+		},
+		{
+			Name: "where",
+			Builder: Select("id", "name").From("users").
+				Where(Eq("id", 1), If(name != "", Like("name", name+"%")), In("role", roles)).
+				OrderBy("id DESC").
+				Limit(20).
+				Offset(40),
+			SQL:  "SELECT id, name FROM users WHERE id = ? AND role IN (?,?) ORDER BY id DESC LIMIT 20 OFFSET 40",
+			Args: []any{1, "admin", "owner"},
+		},
+		{
+			Name: "nested",
+			Builder: Select("id").From("users").
+				Where(Or(IsNull("deleted_at"), And(Gt("age", 18), Lte("age", 60))), Not(NotIn("id", []int{1}))),
+			SQL:  "SELECT id FROM users WHERE (deleted_at IS NULL OR (age > ? AND age <= ?)) AND NOT (id NOT IN (?))",
+			Args: []any{18, 60, 1},
+		},
+		{
+			Name:    "empty_in",
+			Builder: Select("id").From("users").Where(In("id", []int{}), NotIn("role", nil)),
+			SQL:     "SELECT id FROM users WHERE 1 = 0 AND 1 = 1",
+			Args:    []any{},
+		},
+		{
+			Name:    "empty_conds",
+			Builder: Select("id").From("users").Where(nil, And(If(false, Eq("id", 1))), Not(Or())),
+			SQL:     "SELECT id FROM users",
+			Args:    []any{},
+		},
+		{
+			Name: "group",
+			Builder: Select("role", "COUNT(*)").From("users").
+				Where(Expr("created_at > ?", "2024-01-01"), NotEq("role", "guest")).
+				GroupBy("role").
+				Having(Gte("COUNT(*)", 2)),
+			SQL:  "SELECT role, COUNT(*) FROM users WHERE created_at > ? AND role <> ? GROUP BY role HAVING COUNT(*) >= ?",
+			Args: []any{"2024-01-01", "guest", 2},
+		},
+		{
+			Name: "expr_slice",
+			Builder: Select("id").From("users").
+				Where(Expr("id IN (?) AND name <> '?' AND role = ?", []int64{1, 2, 3}, "admin")),
+			SQL:  "SELECT id FROM users WHERE id IN (?,?,?) AND name <> '?' AND role = ?",
+			Args: []any{int64(1), int64(2), int64(3), "admin"},
+		},
+		{
+			Name:    "expr_valuer",
+			Builder: Select("id").From("users").Where(Expr("id = ANY(?)", int64Array{1, 2})),
+			SQL:     "SELECT id FROM users WHERE id = ANY(?)",
+			Args:    []any{int64Array{1, 2}},
+		},
+		{
+			Name:    "fetch",
+			Builder: Select("id").From("users").OrderBy("id").Limit(20).Offset(40).Driver("sqlserver"),
+			SQL:     "SELECT id FROM users ORDER BY id OFFSET 40 ROWS FETCH NEXT 20 ROWS ONLY",
+			Args:    []any{},
+		},
+		{
+			Name:    "fetch_limit",
+			Builder: Select("id").From("users").OrderBy("id").Limit(20).Driver("godror"),
+			SQL:     "SELECT id FROM users ORDER BY id OFFSET 0 ROWS FETCH NEXT 20 ROWS ONLY",
+			Args:    []any{},
+		},
+		{
+			Name:    "fetch_offset",
+			Builder: Select("id").From("users").OrderBy("id").Offset(40).Driver("sqlserver"),
+			SQL:     "SELECT id FROM users ORDER BY id OFFSET 40 ROWS",
+			Args:    []any{},
+		},
+		{
+			Name:    "limit_driver",
+			Builder: Select("id").From("users").Limit(20).Offset(40).Driver("postgres"),
+			SQL:     "SELECT id FROM users LIMIT 20 OFFSET 40",
+			Args:    []any{},
+		},
+		{
+			Name:    "count",
+			Builder: Select("id").From("users").Where(Lt("id", 10)).OrderBy("id").Limit(1).Count(),
+			SQL:     "SELECT COUNT(*) FROM users WHERE id < ?",
+			Args:    []any{10},
+		},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.Name, func(t *testing.T) {
+			sql, args, err := testcase.Builder.Build()
+			if err != nil {
+				t.Errorf("build: %s", err)
+				return
+			}
+			if sql != testcase.SQL {
+				t.Errorf("build: %q != %q", sql, testcase.SQL)
+				return
+			}
+			if !reflect.DeepEqual(args, testcase.Args) {
+				t.Errorf("build: %v != %v", args, testcase.Args)
+				return
+			}
+			if n := len(__rt.MergeArgs(args)); n != len(testcase.Args) {
+				t.Errorf("build: %d merged args != %d", n, len(testcase.Args))
+				return
+			}
+		})
+	}
+}
+
+func TestSelectWithoutFrom(t *testing.T) {
+	if _, _, err := Select("id").Build(); err == nil {
+		t.Errorf("build: expects error, got nil")
+	}
+}
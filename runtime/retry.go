@@ -0,0 +1,146 @@
+package defc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Attempt is a failed attempt of a method generated with the api/retry feature, which is given to the `Retryable` and
+// `Backoff` methods of the `Options()` type to decide whether and when it is retried:
+//
+//	func (c *Config) Retryable(attempt *defc.Attempt) bool {
+//		return attempt.Idempotent() && (attempt.Response == nil || attempt.Response.StatusCode >= 500)
+//	}
+//
+//	func (c *Config) Backoff(attempt *defc.Attempt) time.Duration {
+//		return defc.ExpBackoff(100*time.Millisecond, 2*time.Second)(attempt.Count)
+//	}
+type Attempt struct {
+	// Caller is the name of the generated method.
+	Caller string
+
+	// Method is the HTTP method of the request.
+	Method string
+
+	// Count is the number of attempts retried before this one, starting from 0.
+	Count int
+
+	// Response is the response of the attempt if it failed with an unexpected status code, or nil if it failed
+	// sending its request or reading its response, such as a network error.
+	Response *http.Response
+
+	// Err is the error returned by the attempt.
+	Err error
+}
+
+// Idempotent reports whether the HTTP method of the attempt is idempotent, so that retrying it is safe even if the
+// server handled the failed request.
+func (attempt *Attempt) Idempotent() bool {
+	switch attempt.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of a 429 Too Many Requests or a 503 Service
+// Unavailable response, which is either a number of seconds or an HTTP date.
+func (attempt *Attempt) RetryAfter() (time.Duration, bool) {
+	if attempt.Response == nil {
+		return 0, false
+	}
+	if status := attempt.Response.StatusCode; status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := attempt.Response.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Backoff returns the delay before retrying the attempt numbered count, starting from 0.
+type Backoff func(count int) time.Duration
+
+// ExpBackoff returns a Backoff doubling base for each retry up to max, of which a random half is subtracted as jitter,
+// so that clients failing together do not retry together.
+func ExpBackoff(base time.Duration, max time.Duration) Backoff {
+	return func(count int) time.Duration {
+		delay := base
+		for i := 0; i < count && delay < max; i++ {
+			delay *= 2
+		}
+		if delay > max {
+			delay = max
+		}
+		if half := int64(delay / 2); half > 0 {
+			delay = time.Duration(half + rand.Int63n(half+1))
+		}
+		return delay
+	}
+}
+
+// Retry reports whether the failed attempt should be retried, after waiting for its delay. The attempt is not retried
+// if ctx is done, if the `Retryable(*Attempt) bool` method of options returns false, or if its delay would exceed
+// deadline. The delay is given by the `Backoff(*Attempt) time.Duration` method of options, or by backoff, and is
+// extended to the Retry-After header of the response if any. The response body of a retried attempt is closed once
+// the delay has passed. The Response of attempt is taken from its Err if it is a FutureResponseError. A nil ctx, options, backoff or a zero deadline is ignored.
+func Retry(ctx context.Context, options any, attempt *Attempt, backoff Backoff, deadline time.Time) bool {
+	if ctx != nil && ctx.Err() != nil {
+		return false
+	}
+	var futureResponseError FutureResponseError
+	if attempt.Response == nil && errors.As(attempt.Err, &futureResponseError) {
+		attempt.Response = futureResponseError.Response()
+	}
+	if retryable, ok := options.(interface{ Retryable(attempt *Attempt) bool }); ok && !retryable.Retryable(attempt) {
+		return false
+	}
+	var delay time.Duration
+	if optionsBackoff, ok := options.(interface {
+		Backoff(attempt *Attempt) time.Duration
+	}); ok {
+		delay = optionsBackoff.Backoff(attempt)
+	} else if backoff != nil {
+		delay = backoff(attempt.Count)
+	}
+	if retryAfter, ok := attempt.RetryAfter(); ok && retryAfter > delay {
+		delay = retryAfter
+	}
+	if !deadline.IsZero() && !time.Now().Add(delay).Before(deadline) {
+		return false
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		var done <-chan struct{}
+		if ctx != nil {
+			done = ctx.Done()
+		}
+		select {
+		case <-timer.C:
+		case <-done:
+			return false
+		}
+	}
+	if attempt.Response != nil {
+		attempt.Response.Body.Close()
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package defc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRetryOptions struct {
+	retryable bool
+	backoff   time.Duration
+}
+
+func (o *testRetryOptions) Retryable(attempt *Attempt) bool { return o.retryable }
+
+func (o *testRetryOptions) Backoff(attempt *Attempt) time.Duration { return o.backoff }
+
+type testRetryBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *testRetryBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestExpBackoff(t *testing.T) {
+	backoff := ExpBackoff(100*time.Millisecond, time.Second)
+	for count, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		if delay := backoff(count); delay < max/2 || delay > max {
+			t.Errorf("backoff: %s is not within [%s, %s]", delay, max/2, max)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	newAttempt := func(status int, retryAfter string) (*Attempt, *testRetryBody) {
+		body := &testRetryBody{Reader: strings.NewReader("")}
+		response := &http.Response{StatusCode: status, Header: http.Header{}, Body: body}
+		if retryAfter != "" {
+			response.Header.Set("Retry-After", retryAfter)
+		}
+		return &Attempt{Caller: "Get", Method: http.MethodGet, Response: response, Err: NewFutureResponseError("Get", response)}, body
+	}
+	attempt, body := newAttempt(http.StatusInternalServerError, "")
+	attempt.Response = nil
+	if !Retry(context.Background(), nil, attempt, nil, time.Time{}) || !body.closed || attempt.Response == nil {
+		t.Errorf("retry: expects a retried attempt with its response body closed")
+		return
+	}
+	attempt, body = newAttempt(http.StatusInternalServerError, "")
+	if Retry(context.Background(), &testRetryOptions{retryable: false}, attempt, nil, time.Time{}) || body.closed {
+		t.Errorf("retry: expects Retryable of options to decide")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if attempt, _ = newAttempt(http.StatusInternalServerError, ""); Retry(ctx, nil, attempt, nil, time.Time{}) {
+		t.Errorf("retry: expects canceled context to stop retrying")
+		return
+	}
+	attempt, _ = newAttempt(http.StatusServiceUnavailable, "1")
+	if delay, ok := attempt.RetryAfter(); !ok || delay != time.Second {
+		t.Errorf("retry: %s != %s", delay, time.Second)
+		return
+	}
+	// the delay of Retry-After exceeds the deadline
+	if Retry(context.Background(), nil, attempt, nil, time.Now().Add(100*time.Millisecond)) {
+		t.Errorf("retry: expects the deadline to stop retrying")
+		return
+	}
+	attempt, _ = newAttempt(http.StatusTooManyRequests, time.Now().Add(-time.Second).UTC().Format(http.TimeFormat))
+	if delay, ok := attempt.RetryAfter(); !ok || delay != 0 {
+		t.Errorf("retry: %s != 0", delay)
+		return
+	}
+	start := time.Now()
+	attempt = &Attempt{Caller: "Post", Method: http.MethodPost, Err: errors.New("connection reset")}
+	if !Retry(nil, &testRetryOptions{retryable: true, backoff: 10 * time.Millisecond}, attempt, nil, time.Time{}) ||
+		time.Since(start) < 10*time.Millisecond {
+		t.Errorf("retry: expects Backoff of options to delay the retry")
+		return
+	}
+	if attempt.Idempotent() || attempt.Response != nil {
+		t.Errorf("retry: unexpected idempotent POST attempt or response")
+		return
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if Retry(ctx, nil, attempt, ExpBackoff(time.Second, time.Second), time.Time{}) {
+		t.Errorf("retry: expects context done while waiting to stop retrying")
+	}
+}
@@ -0,0 +1,534 @@
+// Package sqlxtest provides an in-memory Core for unit testing code generated in sqlx mode without a real database.
+//
+// A Core is a *sqlx.DB backed by a fake database/sql driver, so it satisfies the generated XCoreInterface (BeginTxx,
+// Rebind, DriverName), every statement runs in a real *sqlx.Tx, and results are scanned by the sqlx fork exactly as
+// they would be against a database, including types implementing FromRow and FromRows. Statements are matched against
+// expectations declared by the test, in the spirit of sqlmock, and recorded together with their arguments.
+package sqlxtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	__rt "github.com/x5iu/defc/runtime"
+	"github.com/x5iu/defc/sqlx"
+)
+
+// Statement is a statement executed through a Core.
+type Statement struct {
+	// Method is the name of the generated method which executed the statement, it is only known when the generated
+	// code calls the Log hook of the Core, i.e. when the sqlx/log feature is enabled.
+	Method string
+	Query  string
+	Args   []any
+	Exec   bool
+	Err    error
+
+	ctx         context.Context
+	expectation *Expectation
+}
+
+// Core is a fake core for generated sqlx code, use New to create one.
+type Core struct {
+	*sqlx.DB
+
+	mu           sync.Mutex
+	ordered      bool
+	expectations []*Expectation
+	statements   []*Statement
+	begins       int
+	commits      int
+	rollbacks    int
+}
+
+// New returns a Core which reports driverName (e.g. "postgres" or "sqlite3") as its driver name, so that Rebind and
+// the dialect-specific behaviors of the runtime act as they would for that driver.
+func New(driverName string) *Core {
+	core := &Core{ordered: true}
+	core.DB = sqlx.NewDB(sql.OpenDB(&connector{core: core}), driverName)
+	return core
+}
+
+// CoreBeginTx implements the XCoreBeginTxInterface of code generated with the sqlx/rebind feature; code generated
+// without it begins transactions through BeginTxx instead, both of them result in the same records.
+func (core *Core) CoreBeginTx(ctx context.Context, opts *sql.TxOptions) (__rt.TxRebindInterface, error) {
+	return core.BeginTxx(ctx, opts)
+}
+
+// Log implements the hook of the sqlx/log feature, which is used to attribute the statement logged by each call to
+// caller. Since the hook is called right after the statement, it is attributed the latest statement not attributed
+// yet with the same arguments, preferring the same query (which differs with sqlx/interpolate) and the same context
+// (which differs when the statement runs with a deadline). Concurrent calls are thus told apart by their arguments,
+// queries and contexts rather than by their order.
+func (core *Core) Log(ctx context.Context, caller string, query string, args any, _ time.Duration) {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	values, withArgs := args.([]any)
+	if withArgs {
+		values = convertValues(values)
+	}
+	var (
+		attributed *Statement
+		best       = -1
+	)
+	for i := len(core.statements) - 1; i >= 0; i-- {
+		statement := core.statements[i]
+		if statement.Method != "" || (withArgs && !equalValues(statement.Args, values)) {
+			continue
+		}
+		score := 0
+		if exactMatcher(query)(statement.Query) {
+			score += 2
+		}
+		if sameContext(statement.ctx, ctx) {
+			score++
+		}
+		if score > best {
+			attributed, best = statement, score
+		}
+	}
+	if attributed != nil {
+		attributed.Method = caller
+		attributed.ctx = nil
+	}
+}
+
+// MatchInOrder sets whether statements should match expectations in the order they are declared, which is the
+// default. When disabled, a statement matches the first declared expectation that accepts it.
+func (core *Core) MatchInOrder(ordered bool) {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	core.ordered = ordered
+}
+
+// ExpectExec expects an ExecContext call with query, whitespaces are normalized before comparing.
+func (core *Core) ExpectExec(query string) *Expectation {
+	return core.expect(true, strconv.Quote(query), exactMatcher(query))
+}
+
+// ExpectExecRegexp expects an ExecContext call with a query matching pattern.
+func (core *Core) ExpectExecRegexp(pattern string) *Expectation {
+	return core.expect(true, "matching "+strconv.Quote(pattern), regexp.MustCompile(pattern).MatchString)
+}
+
+// ExpectQuery expects a GetContext or SelectContext call with query, whitespaces are normalized before comparing.
+func (core *Core) ExpectQuery(query string) *Expectation {
+	return core.expect(false, strconv.Quote(query), exactMatcher(query))
+}
+
+// ExpectQueryRegexp expects a GetContext or SelectContext call with a query matching pattern.
+func (core *Core) ExpectQueryRegexp(pattern string) *Expectation {
+	return core.expect(false, "matching "+strconv.Quote(pattern), regexp.MustCompile(pattern).MatchString)
+}
+
+func (core *Core) expect(exec bool, query string, match func(string) bool) *Expectation {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	expectation := &Expectation{exec: exec, query: query, match: match, times: 1}
+	core.expectations = append(core.expectations, expectation)
+	return expectation
+}
+
+// ExpectationsWereMet returns an error if any declared expectation has not been fulfilled, or if a statement matched
+// by an expectation restricted with ForMethod has not been attributed to that method.
+func (core *Core) ExpectationsWereMet() error {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	for _, expectation := range core.expectations {
+		if !expectation.fulfilled() {
+			return fmt.Errorf("sqlxtest: expectation %s was not met", expectation)
+		}
+	}
+	for _, statement := range core.statements {
+		expectation := statement.expectation
+		if expectation == nil || expectation.method == "" || statement.Method == expectation.method {
+			continue
+		}
+		if statement.Method == "" {
+			return fmt.Errorf("sqlxtest: expectation %s expects method %s, but statement %q was not attributed "+
+				"to any method, the Log hook of the Core has not been called (is the sqlx/log feature enabled?)",
+				expectation, expectation.method, statement.Query)
+		}
+		return fmt.Errorf("sqlxtest: expectation %s expects method %s, but statement %q was executed by %s",
+			expectation, expectation.method, statement.Query, statement.Method)
+	}
+	return nil
+}
+
+// Statements returns all recorded statements in execution order.
+func (core *Core) Statements() []Statement {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	statements := make([]Statement, len(core.statements))
+	for i, statement := range core.statements {
+		statements[i] = *statement
+	}
+	return statements
+}
+
+// StatementsOf returns the recorded statements executed by method.
+func (core *Core) StatementsOf(method string) []Statement {
+	var statements []Statement
+	for _, statement := range core.Statements() {
+		if statement.Method == method {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// Transactions returns the number of transactions begun, committed and rolled back.
+func (core *Core) Transactions() (begins int, commits int, rollbacks int) {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	return core.begins, core.commits, core.rollbacks
+}
+
+// Reset removes all expectations and records.
+func (core *Core) Reset() {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	core.expectations = nil
+	core.statements = nil
+	core.begins, core.commits, core.rollbacks = 0, 0, 0
+}
+
+func (core *Core) run(ctx context.Context, exec bool, query string, args []driver.NamedValue) (*Expectation, error) {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	statement := &Statement{Query: query, Args: values, Exec: exec, ctx: ctx}
+	core.statements = append(core.statements, statement)
+	var expectation *Expectation
+	for _, candidate := range core.expectations {
+		if candidate.exhausted() {
+			continue
+		}
+		if candidate.accepts(exec, query, values) {
+			expectation = candidate
+			break
+		}
+		if core.ordered && !candidate.fulfilled() {
+			break
+		}
+	}
+	if expectation == nil {
+		kind := "query"
+		if exec {
+			kind = "exec"
+		}
+		statement.Err = fmt.Errorf("sqlxtest: unexpected %s %q with args %v", kind, query, values)
+		return nil, statement.Err
+	}
+	expectation.calls++
+	statement.expectation = expectation
+	statement.Err = expectation.err
+	return expectation, expectation.err
+}
+
+func convertValues(args []any) []any {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		value, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			value = arg
+		}
+		values[i] = value
+	}
+	return values
+}
+
+func equalValues(x []any, y []any) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !reflect.DeepEqual(x[i], y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameContext(x context.Context, y context.Context) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return x == y
+}
+
+func exactMatcher(query string) func(string) bool {
+	expect := strings.Join(strings.Fields(query), " ")
+	return func(query string) bool {
+		return strings.Join(strings.Fields(query), " ") == expect
+	}
+}
+
+// Argument matches an argument of a statement in Expectation.WithArgs.
+type Argument interface {
+	Match(value driver.Value) bool
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(driver.Value) bool { return true }
+
+// AnyArg returns an Argument that matches any value.
+func AnyArg() Argument { return anyArg{} }
+
+// Expectation is an expected statement, it is fulfilled once by default.
+type Expectation struct {
+	exec     bool
+	method   string
+	query    string
+	match    func(string) bool
+	args     []any
+	withArgs bool
+	rows     *Rows
+	result   driver.Result
+	err      error
+	times    int
+	calls    int
+}
+
+// WithArgs restricts the expectation to statements with args, which are compared after being converted to driver
+// values, so that int(1) matches int64(1). Use AnyArg or a custom Argument for flexible matching.
+func (expectation *Expectation) WithArgs(args ...any) *Expectation {
+	expectation.args = args
+	expectation.withArgs = true
+	return expectation
+}
+
+// ForMethod restricts the expectation to statements executed by method, which is checked by ExpectationsWereMet and
+// requires the generated code to call the Log hook of the Core, i.e. the sqlx/log feature; ExpectationsWereMet fails
+// for statements which have not been attributed to any method.
+func (expectation *Expectation) ForMethod(method string) *Expectation {
+	expectation.method = method
+	return expectation
+}
+
+// WillReturnRows sets the rows returned by an expected query.
+func (expectation *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	expectation.rows = rows
+	return expectation
+}
+
+// WillReturnResult sets the result of an expected exec.
+func (expectation *Expectation) WillReturnResult(lastInsertId int64, rowsAffected int64) *Expectation {
+	expectation.result = result{lastInsertId: lastInsertId, rowsAffected: rowsAffected}
+	return expectation
+}
+
+// WillReturnError makes the expected statement fail with err.
+func (expectation *Expectation) WillReturnError(err error) *Expectation {
+	expectation.err = err
+	return expectation
+}
+
+// Times sets how many statements the expectation should match, a negative n matches any number of statements.
+func (expectation *Expectation) Times(n int) *Expectation {
+	expectation.times = n
+	return expectation
+}
+
+// AnyTimes is a shorthand for Times(-1).
+func (expectation *Expectation) AnyTimes() *Expectation {
+	return expectation.Times(-1)
+}
+
+func (expectation *Expectation) fulfilled() bool {
+	return expectation.times < 0 || expectation.calls >= expectation.times
+}
+
+func (expectation *Expectation) exhausted() bool {
+	return expectation.times >= 0 && expectation.calls >= expectation.times
+}
+
+func (expectation *Expectation) accepts(exec bool, query string, args []any) bool {
+	if expectation.exec != exec || !expectation.match(query) {
+		return false
+	}
+	if !expectation.withArgs {
+		return true
+	}
+	if len(expectation.args) != len(args) {
+		return false
+	}
+	for i, expect := range expectation.args {
+		if argument, ok := expect.(Argument); ok {
+			if !argument.Match(args[i]) {
+				return false
+			}
+			continue
+		}
+		value, err := driver.DefaultParameterConverter.ConvertValue(expect)
+		if err != nil {
+			value = expect
+		}
+		if !reflect.DeepEqual(value, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (expectation *Expectation) String() string {
+	kind := "query"
+	if expectation.exec {
+		kind = "exec"
+	}
+	if expectation.withArgs {
+		return fmt.Sprintf("%s %s with args %v (called %d times)",
+			kind, expectation.query, expectation.args, expectation.calls)
+	}
+	return fmt.Sprintf("%s %s (called %d times)", kind, expectation.query, expectation.calls)
+}
+
+// Rows are the canned rows returned by an expected query.
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// NewRows creates Rows with columns.
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow appends a row, values are converted by driver.DefaultParameterConverter and AddRow panics if the number of
+// values mismatches the number of columns or a value cannot be converted.
+func (rows *Rows) AddRow(values ...any) *Rows {
+	if len(values) != len(rows.columns) {
+		panic(fmt.Errorf("sqlxtest: expects %d values, got %d", len(rows.columns), len(values)))
+	}
+	row := make([]driver.Value, len(values))
+	for i, value := range values {
+		converted, err := driver.DefaultParameterConverter.ConvertValue(value)
+		if err != nil {
+			panic(fmt.Errorf("sqlxtest: converting value of column %q: %w", rows.columns[i], err))
+		}
+		row[i] = converted
+	}
+	rows.values = append(rows.values, row)
+	return rows
+}
+
+type result struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastInsertId, nil }
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type connector struct {
+	core *Core
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) { return &conn{core: c.core}, nil }
+func (c *connector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("sqlxtest: use sqlxtest.New to create a Core")
+}
+
+type conn struct {
+	core *Core
+}
+
+func (c *conn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("sqlxtest: prepared statements are not supported")
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.core.mu.Lock()
+	defer c.core.mu.Unlock()
+	c.core.begins++
+	return c, nil
+}
+
+func (c *conn) Commit() error {
+	c.core.mu.Lock()
+	defer c.core.mu.Unlock()
+	c.core.commits++
+	return nil
+}
+
+func (c *conn) Rollback() error {
+	c.core.mu.Lock()
+	defer c.core.mu.Unlock()
+	c.core.rollbacks++
+	return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	expectation, err := c.core.run(ctx, true, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if expectation.result == nil {
+		return result{}, nil
+	}
+	return expectation.result, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	expectation, err := c.core.run(ctx, false, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if expectation.rows == nil {
+		return &rows{}, nil
+	}
+	return &rows{columns: expectation.rows.columns, values: expectation.rows.values}, nil
+}
+
+// CheckNamedValue passes arguments which are not valid driver values (e.g. types implementing neither driver.Valuer
+// nor one of the basic kinds) through as-is, so that they can be recorded and matched by a custom Argument.
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	if converted, err := driver.DefaultParameterConverter.ConvertValue(value.Value); err == nil {
+		value.Value = converted
+	}
+	return nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	index   int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.index >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.index])
+	r.index++
+	return nil
+}
@@ -0,0 +1,274 @@
+package sqlxtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	__rt "github.com/x5iu/defc/runtime"
+)
+
+type user struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+type userFromRow struct {
+	id   int64
+	name string
+}
+
+func (u *userFromRow) FromRow(row __rt.Row) error {
+	return __rt.ScanRow(row, "id", &u.id, "name", &u.name)
+}
+
+func TestCore(t *testing.T) {
+	ctx := context.Background()
+	t.Run("query", func(t *testing.T) {
+		core := New("postgres")
+		defer core.Close()
+		core.ExpectQuery("SELECT * FROM user WHERE id = $1").
+			WithArgs(1).
+			WillReturnRows(NewRows("id", "name").AddRow(1, "defc"))
+		core.ExpectQueryRegexp(`^SELECT \* FROM user$`).
+			WillReturnRows(NewRows("id", "name").AddRow(1, "defc").AddRow(2, "sqlx"))
+		tx, err := core.CoreBeginTx(ctx, nil)
+		if err != nil {
+			t.Errorf("begin: %s", err)
+			return
+		}
+		var one user
+		if err = tx.GetContext(ctx, &one, tx.Rebind("SELECT  *  FROM user WHERE id = ?"), 1); err != nil {
+			t.Errorf("get: %s", err)
+			return
+		}
+		core.Log(ctx, "GetUser", "", nil, 0)
+		if expect := (user{ID: 1, Name: "defc"}); one != expect {
+			t.Errorf("get: %v != %v", one, expect)
+			return
+		}
+		var many []*userFromRow
+		if err = tx.SelectContext(ctx, &many, "SELECT * FROM user"); err != nil {
+			t.Errorf("select: %s", err)
+			return
+		}
+		core.Log(ctx, "ListUsers", "", nil, 0)
+		if len(many) != 2 || *many[1] != (userFromRow{id: 2, name: "sqlx"}) {
+			t.Errorf("select: unexpected result %v", many)
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			t.Errorf("commit: %s", err)
+			return
+		}
+		if err = core.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations: %s", err)
+			return
+		}
+		statements := core.StatementsOf("GetUser")
+		if len(statements) != 1 || !reflect.DeepEqual(statements[0].Args, []any{int64(1)}) {
+			t.Errorf("statements: unexpected records %v", statements)
+			return
+		}
+		if begins, commits, rollbacks := core.Transactions(); begins != 1 || commits != 1 || rollbacks != 0 {
+			t.Errorf("transactions: %d, %d, %d", begins, commits, rollbacks)
+			return
+		}
+	})
+	t.Run("exec", func(t *testing.T) {
+		core := New("sqlite3")
+		defer core.Close()
+		core.ExpectExec("INSERT INTO user (name) VALUES (?)").
+			WithArgs(AnyArg()).
+			WillReturnResult(3, 1)
+		tx, err := core.BeginTxx(ctx, nil)
+		if err != nil {
+			t.Errorf("begin: %s", err)
+			return
+		}
+		defer tx.Rollback()
+		result, err := tx.ExecContext(ctx, "INSERT INTO user (name) VALUES (?)", "defc")
+		if err != nil {
+			t.Errorf("exec: %s", err)
+			return
+		}
+		if id, _ := result.LastInsertId(); id != 3 {
+			t.Errorf("exec: last insert id %d != 3", id)
+			return
+		}
+		if _, err = tx.ExecContext(ctx, "DELETE FROM user"); err == nil {
+			t.Errorf("exec: expects unexpected exec error, got nil")
+			return
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		core := New("sqlite3")
+		defer core.Close()
+		expectErr := errors.New("expected error")
+		core.ExpectQuery("SELECT name FROM user").WillReturnError(expectErr)
+		core.ExpectQuery("SELECT name FROM user").WillReturnRows(NewRows("name"))
+		var name string
+		if err := core.GetContext(ctx, &name, "SELECT name FROM user"); !errors.Is(err, expectErr) {
+			t.Errorf("get: expects %s, got %v", expectErr, err)
+			return
+		}
+		if err := core.GetContext(ctx, &name, "SELECT name FROM user"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("get: expects sql.ErrNoRows, got %v", err)
+			return
+		}
+	})
+	t.Run("unmet", func(t *testing.T) {
+		core := New("sqlite3")
+		defer core.Close()
+		core.ExpectExec("DELETE FROM user").WithArgs(1)
+		if err := core.ExpectationsWereMet(); err == nil ||
+			!strings.Contains(err.Error(), `exec "DELETE FROM user" with args [1] (called 0 times)`) {
+			t.Errorf("expectations: expects unmet exec error, got %v", err)
+			return
+		}
+		core.Reset()
+		core.ExpectQueryRegexp("^SELECT .* FROM user$")
+		if err := core.ExpectationsWereMet(); err == nil ||
+			!strings.Contains(err.Error(), `query matching "^SELECT .* FROM user$" (called 0 times)`) {
+			t.Errorf("expectations: expects unmet query error, got %v", err)
+		}
+	})
+	t.Run("attribution", func(t *testing.T) {
+		core := New("sqlite3")
+		defer core.Close()
+		core.ExpectExec("DELETE FROM user WHERE id = ?").AnyTimes()
+		const query = "DELETE FROM user WHERE id = ?"
+		ctxA, cancelA := context.WithCancel(ctx)
+		defer cancelA()
+		ctxB, cancelB := context.WithCancel(ctx)
+		defer cancelB()
+		for _, call := range []struct {
+			ctx context.Context
+			arg int
+		}{{ctxA, 1}, {ctxB, 2}, {ctxA, 3}, {ctxB, 3}} {
+			if _, err := core.ExecContext(call.ctx, query, call.arg); err != nil {
+				t.Errorf("exec: %s", err)
+				return
+			}
+		}
+		core.Log(ctxB, "DeleteB", query, []any{2}, 0)
+		core.Log(ctxA, "DeleteA", query, []any{3}, 0)
+		core.Log(ctxA, "DeleteA", query, []any{1}, 0)
+		core.Log(ctxB, "DeleteB", query, []any{int64(3)}, 0)
+		for method, expect := range map[string][]any{
+			"DeleteA": {int64(1), int64(3)},
+			"DeleteB": {int64(2), int64(3)},
+		} {
+			var (
+				statements = core.StatementsOf(method)
+				args       []any
+			)
+			for _, statement := range statements {
+				args = append(args, statement.Args...)
+			}
+			if !reflect.DeepEqual(args, expect) {
+				t.Errorf("attribution: %s executed %v, expects %v", method, args, expect)
+				return
+			}
+		}
+		statements := core.Statements()
+		if statements[2].Method != "DeleteA" || statements[3].Method != "DeleteB" {
+			t.Errorf("attribution: statements of the same args are not told apart by their contexts")
+			return
+		}
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		core := New("sqlite3")
+		defer core.Close()
+		core.MatchInOrder(false)
+		const n = 32
+		for i := 0; i < n; i++ {
+			core.ExpectExec("UPDATE user SET name = ? WHERE id = ?").
+				WithArgs(AnyArg(), i).
+				ForMethod("Update" + strconv.Itoa(i))
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				query, args := "UPDATE user SET name = ? WHERE id = ?", []any{"defc", i}
+				if _, err := core.ExecContext(ctx, query, args...); err != nil {
+					t.Errorf("exec: %s", err)
+				}
+				core.Log(ctx, "Update"+strconv.Itoa(i), query, args, 0)
+			}(i)
+		}
+		wg.Wait()
+		if err := core.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations: %s", err)
+			return
+		}
+	})
+	t.Run("method", func(t *testing.T) {
+		core := New("sqlite3")
+		defer core.Close()
+		core.ExpectExec("DELETE FROM user").ForMethod("DeleteUsers")
+		if _, err := core.ExecContext(ctx, "DELETE FROM user"); err != nil {
+			t.Errorf("exec: %s", err)
+			return
+		}
+		if err := core.ExpectationsWereMet(); err == nil ||
+			!strings.Contains(err.Error(), "the Log hook of the Core has not been called") {
+			t.Errorf("expectations: expects unattributed statement error, got %v", err)
+			return
+		}
+		core.Log(ctx, "ClearUsers", "DELETE FROM user", []any{}, 0)
+		if err := core.ExpectationsWereMet(); err == nil ||
+			!strings.Contains(err.Error(), "expects method DeleteUsers, but statement \"DELETE FROM user\" was executed by ClearUsers") {
+			t.Errorf("expectations: expects mismatched method error, got %v", err)
+			return
+		}
+		core.Reset()
+		core.ExpectExec("DELETE FROM user").ForMethod("DeleteUsers")
+		if _, err := core.ExecContext(ctx, "DELETE FROM user"); err != nil {
+			t.Errorf("exec: %s", err)
+			return
+		}
+		core.Log(ctx, "DeleteUsers", "DELETE FROM user", []any{}, 0)
+		if err := core.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations: %s", err)
+			return
+		}
+	})
+	t.Run("order", func(t *testing.T) {
+		core := New("sqlite3")
+		defer core.Close()
+		core.ExpectExec("DELETE FROM a")
+		core.ExpectExec("DELETE FROM b")
+		if _, err := core.ExecContext(ctx, "DELETE FROM b"); err == nil {
+			t.Errorf("exec: expects out of order error, got nil")
+			return
+		}
+		core.Reset()
+		core.MatchInOrder(false)
+		core.ExpectExec("DELETE FROM a")
+		core.ExpectExec("DELETE FROM b").Times(2)
+		core.ExpectExecRegexp(".*").WithArgs(time.Time{}).AnyTimes()
+		for _, query := range []string{"DELETE FROM b", "DELETE FROM a", "DELETE FROM b"} {
+			if _, err := core.ExecContext(ctx, query); err != nil {
+				t.Errorf("exec: %s", err)
+				return
+			}
+		}
+		if _, err := core.ExecContext(ctx, "DELETE FROM c", time.Time{}); err != nil {
+			t.Errorf("exec: %s", err)
+			return
+		}
+		if err := core.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations: %s", err)
+			return
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package defc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/x5iu/defc/sqlx"
+)
+
+// txKey identifies the transactions of a db in a context. Comparable dbs are keyed by their values, while maps,
+// slices and funcs, which cannot be compared, are keyed by their types and pointers.
+type txKey struct{ db any }
+
+type txPointer struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// newTxKey returns the key of db, ok is false if db has no identity to be keyed on, such as a struct value holding a
+// map or a func.
+func newTxKey(db any) (key txKey, ok bool) {
+	if isComparable(db) {
+		return txKey{db}, true
+	}
+	switch v := reflect.ValueOf(db); v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Func:
+		return txKey{txPointer{v.Type(), v.Pointer()}}, true
+	}
+	return txKey{}, false
+}
+
+// isComparable reports whether v can be compared with ==, which also depends on the dynamic values of its interface
+// fields.
+func isComparable(v any) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return v == v
+}
+
+// ContextWithTx returns a copy of ctx carrying tx, a transaction begun on db. With the sqlx/context-tx feature,
+// generated methods of every schema constructed from the same db run in tx when they are called with the returned
+// context, instead of beginning their own transactions, and leave committing and rolling back tx to the caller.
+// db is the value given to the constructors of schemas, usually the *sqlx.DB passed to New<Schema>FromDB. Like
+// context.WithValue, ContextWithTx panics if db cannot be told apart from other values, that is, if it is neither
+// comparable nor a map, slice or func; use a pointer to such a core instead.
+func ContextWithTx(ctx context.Context, db any, tx any) context.Context {
+	key, ok := newTxKey(db)
+	if !ok {
+		panic(fmt.Sprintf("defc: ContextWithTx: db of type %T is not comparable", db))
+	}
+	return context.WithValue(ctx, key, tx)
+}
+
+// TxFromContext returns the transaction of db carried by ctx, or nil if there is none.
+func TxFromContext(ctx context.Context, db any) any {
+	key, ok := newTxKey(db)
+	if !ok {
+		return nil
+	}
+	return ctx.Value(key)
+}
+
+// BeginTx begins a transaction on db and returns a copy of ctx carrying it, see ContextWithTx.
+func BeginTx(ctx context.Context, db *sqlx.DB, opts *sql.TxOptions) (context.Context, *sqlx.Tx, error) {
+	tx, err := db.BeginTxx(ctx, opts)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return ContextWithTx(ctx, db, tx), tx, nil
+}
+
+// RunInTx calls f with a context carrying a transaction begun on db, which is committed if f returns nil and rolled
+// back otherwise (or if f panics). If ctx already carries a transaction of db, f joins it and RunInTx leaves it to
+// its owner.
+func RunInTx(ctx context.Context, db *sqlx.DB, f func(ctx context.Context) error) (err error) {
+	if TxFromContext(ctx, db) != nil {
+		return f(ctx)
+	}
+	txCtx, tx, err := BeginTx(ctx, db, nil)
+	if err != nil {
+		return fmt.Errorf("defc: begin transaction: %w", err)
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			tx.Rollback()
+			panic(v)
+		}
+	}()
+	if err = f(txCtx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("defc: commit transaction: %w", err)
+	}
+	return nil
+}
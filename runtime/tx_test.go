@@ -0,0 +1,128 @@
+package defc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/x5iu/defc/sqlx"
+)
+
+// txConnector counts the transactions begun, committed and rolled back on its connections.
+type txConnector struct{ begins, commits, rollbacks int }
+
+func (c *txConnector) Connect(context.Context) (driver.Conn, error) { return &txConn{c}, nil }
+func (c *txConnector) Driver() driver.Driver                        { return nil }
+
+type txConn struct{ connector *txConnector }
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *txConn) Close() error                        { return nil }
+func (c *txConn) Begin() (driver.Tx, error) {
+	c.connector.begins++
+	return c, nil
+}
+func (c *txConn) Commit() error {
+	c.connector.commits++
+	return nil
+}
+func (c *txConn) Rollback() error {
+	c.connector.rollbacks++
+	return nil
+}
+
+func TestRunInTx(t *testing.T) {
+	var (
+		connector = &txConnector{}
+		db        = sqlx.NewDB(sql.OpenDB(connector), "stub")
+		ctx       = context.Background()
+	)
+	defer db.Close()
+	if TxFromContext(ctx, db) != nil {
+		t.Errorf("tx: unexpected transaction in background context")
+		return
+	}
+	err := RunInTx(ctx, db, func(ctx context.Context) error {
+		tx, ok := TxFromContext(ctx, db).(*sqlx.Tx)
+		if !ok || tx == nil {
+			return errors.New("no transaction in context")
+		}
+		if TxFromContext(ctx, &sqlx.DB{}) != nil {
+			return errors.New("unexpected transaction of another db")
+		}
+		return RunInTx(ctx, db, func(inner context.Context) error {
+			if TxFromContext(inner, db) != tx {
+				return errors.New("nested RunInTx does not join the transaction")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Errorf("tx: %s", err)
+		return
+	}
+	if connector.begins != 1 || connector.commits != 1 || connector.rollbacks != 0 {
+		t.Errorf("tx: begins=%d, commits=%d, rollbacks=%d", connector.begins, connector.commits, connector.rollbacks)
+		return
+	}
+	expect := errors.New("expected")
+	if err = RunInTx(ctx, db, func(context.Context) error { return expect }); !errors.Is(err, expect) {
+		t.Errorf("tx: %v != %v", err, expect)
+		return
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("tx: expects panic")
+			}
+		}()
+		RunInTx(ctx, db, func(context.Context) error { panic("expected") })
+	}()
+	if connector.begins != 3 || connector.commits != 1 || connector.rollbacks != 2 {
+		t.Errorf("tx: begins=%d, commits=%d, rollbacks=%d", connector.begins, connector.commits, connector.rollbacks)
+	}
+}
+
+func TestTxKey(t *testing.T) {
+	type funcCore struct {
+		exec func(string) error
+	}
+	type anyCore struct {
+		core any
+	}
+	var (
+		ctx     = context.Background()
+		mapCore = map[string]int{}
+		sliceA  = make([]int, 1)
+		sliceB  = make([]int, 1)
+		fn      = func() {}
+	)
+	for _, db := range []any{mapCore, sliceA, fn, anyCore{1}} {
+		txCtx := ContextWithTx(ctx, db, "tx")
+		if tx := TxFromContext(txCtx, db); tx != "tx" {
+			t.Errorf("tx: %T: %v != tx", db, tx)
+			return
+		}
+	}
+	if tx := TxFromContext(ContextWithTx(ctx, sliceA, "tx"), sliceB); tx != nil {
+		t.Errorf("tx: unexpected transaction of another slice")
+		return
+	}
+	for _, db := range []any{funcCore{}, anyCore{mapCore}} {
+		txCtx := ContextWithTx(ctx, mapCore, "tx")
+		if tx := TxFromContext(txCtx, db); tx != nil {
+			t.Errorf("tx: %T: unexpected transaction %v", db, tx)
+			return
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("tx: %T: expects panic", db)
+				}
+			}()
+			ContextWithTx(ctx, db, "tx")
+		}()
+	}
+}
@@ -0,0 +1,249 @@
+package sqlx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/x5iu/defc/sqlx/reflectx"
+)
+
+// Group wraps dest, a pointer to a slice of structs (or pointers to structs), so that rows of a JOIN query are folded
+// into one element per distinct value of the keys columns, instead of one element per row:
+//
+//	type User struct {
+//		ID       int64      `db:"id"`
+//		Name     string     `db:"name"`
+//		Projects []*Project `db:"project"`
+//	}
+//
+//	var users []*User
+//	err := db.Select(Group(&users, "id"), `
+//		SELECT user.id, user.name, project.id AS "project.id", project.name AS "project.name"
+//		FROM user LEFT JOIN project ON project.user_id = user.id`)
+//
+// Columns prefixed by the name of a slice field, such as "project.id", are scanned into a new element appended to
+// that field; the element is skipped when all of its columns are NULL (a LEFT JOIN without match), or when the same
+// values have already been appended to the same parent (rows multiplied by joining several slice fields).
+func Group(dest any, keys ...string) FromRows {
+	return &group{dest: dest, keys: keys}
+}
+
+type group struct {
+	dest any
+	keys []string
+}
+
+// groupChild is a slice field of the parent struct, whose columns are scanned into holders of type **T first, so
+// that NULL values of unmatched LEFT JOIN rows can be detected.
+type groupChild struct {
+	field   []int
+	elem    reflect.Type
+	isPtr   bool
+	columns []int
+	fields  [][]int
+}
+
+func (g *group) FromRows(rows IRows) error {
+	if len(g.keys) == 0 {
+		return errors.New("sqlx.Group: no key column")
+	}
+	value := reflect.ValueOf(g.dest)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return errors.New("sqlx.Group: must pass a non-nil pointer to the destination slice")
+	}
+	direct := reflect.Indirect(value)
+	slice, err := baseType(value.Type(), reflect.Slice)
+	if err != nil {
+		return err
+	}
+	isPtr := slice.Elem().Kind() == reflect.Ptr
+	base := reflectx.Deref(slice.Elem())
+	if base.Kind() != reflect.Struct || isScannable(base) {
+		return fmt.Errorf("sqlx.Group: expects a slice of structs, got %s", slice)
+	}
+	direct.SetLen(0)
+
+	var (
+		m      *reflectx.Mapper
+		unsafe = isUnsafe(rows)
+	)
+	if r, ok := rows.(*Rows); ok {
+		m = r.Mapper
+	} else {
+		m = mapper()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	var (
+		parentFields = m.TraversalsByName(base, columns)
+		children     []*groupChild
+		childOf      = make([]*groupChild, len(columns))
+		typeMap      = m.TypeMap(base)
+	)
+	for i, column := range columns {
+		if len(parentFields[i]) > 0 {
+			continue
+		}
+		dot := strings.IndexByte(column, '.')
+		if dot < 0 {
+			if !unsafe {
+				return fmt.Errorf("missing destination name %s in %T", column, g.dest)
+			}
+			continue
+		}
+		info, ok := typeMap.Names[column[:dot]]
+		if !ok || info.Field.Type.Kind() != reflect.Slice || reflectx.Deref(info.Field.Type.Elem()).Kind() != reflect.Struct {
+			if !unsafe {
+				return fmt.Errorf("missing destination name %s in %T", column, g.dest)
+			}
+			continue
+		}
+		var child *groupChild
+		for _, c := range children {
+			if equalIndex(c.field, info.Index) {
+				child = c
+			}
+		}
+		if child == nil {
+			child = &groupChild{
+				field: info.Index,
+				elem:  reflectx.Deref(info.Field.Type.Elem()),
+				isPtr: info.Field.Type.Elem().Kind() == reflect.Ptr,
+			}
+			children = append(children, child)
+		}
+		traversal := m.TraversalsByName(child.elem, []string{column[dot+1:]})[0]
+		if len(traversal) == 0 {
+			if !unsafe {
+				return fmt.Errorf("missing destination name %s in %T", column, g.dest)
+			}
+			continue
+		}
+		child.columns = append(child.columns, i)
+		child.fields = append(child.fields, traversal)
+		childOf[i] = child
+	}
+
+	keyIndexes := make([][]int, len(g.keys))
+	for i, key := range g.keys {
+		for j, column := range columns {
+			if column == key && len(parentFields[j]) > 0 {
+				keyIndexes[i] = parentFields[j]
+			}
+		}
+		if keyIndexes[i] == nil {
+			return fmt.Errorf("sqlx.Group: key column %s not found in %T", key, g.dest)
+		}
+	}
+
+	var (
+		values  = make([]any, len(columns))
+		parents = make(map[string]int)
+		seen    = make(map[string]struct{})
+	)
+	for rows.Next() {
+		vp := reflect.New(base)
+		v := reflect.Indirect(vp)
+		for i := range columns {
+			switch {
+			case len(parentFields[i]) > 0:
+				values[i] = reflectx.FieldByIndexes(v, parentFields[i]).Addr().Interface()
+			case childOf[i] != nil:
+				// set by the loop of children below
+			default:
+				values[i] = new(any)
+			}
+		}
+		for _, child := range children {
+			for j, column := range child.columns {
+				values[column] = reflect.New(reflect.PointerTo(fieldType(child.elem, child.fields[j]))).Interface()
+			}
+		}
+		if err = rows.Scan(values...); err != nil {
+			return err
+		}
+
+		key := groupKey(v, keyIndexes)
+		index, ok := parents[key]
+		if !ok {
+			if isPtr {
+				direct.Set(reflect.Append(direct, vp))
+			} else {
+				direct.Set(reflect.Append(direct, v))
+			}
+			index = direct.Len() - 1
+			parents[key] = index
+		}
+		// elements are looked up by index, since appending to the slice may move its elements
+		parent := reflect.Indirect(direct.Index(index))
+
+		for childIndex, child := range children {
+			var (
+				allNull  = true
+				childKey strings.Builder
+			)
+			for _, column := range child.columns {
+				holder := reflect.ValueOf(values[column]).Elem()
+				if !holder.IsNil() {
+					allNull = false
+					fmt.Fprintf(&childKey, "%v", holder.Elem().Interface())
+				}
+				childKey.WriteByte(0)
+			}
+			if allNull {
+				continue
+			}
+			dedupe := fmt.Sprintf("%s\x00%d\x00%s", key, childIndex, childKey.String())
+			if _, ok := seen[dedupe]; ok {
+				continue
+			}
+			seen[dedupe] = struct{}{}
+			cp := reflect.New(child.elem)
+			for j, column := range child.columns {
+				if holder := reflect.ValueOf(values[column]).Elem(); !holder.IsNil() {
+					reflectx.FieldByIndexes(cp.Elem(), child.fields[j]).Set(holder.Elem())
+				}
+			}
+			field := reflectx.FieldByIndexes(parent, child.field)
+			if child.isPtr {
+				field.Set(reflect.Append(field, cp))
+			} else {
+				field.Set(reflect.Append(field, cp.Elem()))
+			}
+		}
+	}
+	return nil
+}
+
+func equalIndex(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldType(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		t = reflectx.Deref(t).Field(i).Type
+	}
+	return t
+}
+
+func groupKey(v reflect.Value, indexes [][]int) string {
+	var key strings.Builder
+	for _, index := range indexes {
+		fmt.Fprintf(&key, "%v", reflect.Indirect(reflectx.FieldByIndexesReadOnly(v, index)).Interface())
+		key.WriteByte(0)
+	}
+	return key.String()
+}
@@ -0,0 +1,112 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubConnector returns its columns and values for every query.
+type stubConnector struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (c *stubConnector) Connect(context.Context) (driver.Conn, error) { return &stubConn{c}, nil }
+func (c *stubConnector) Driver() driver.Driver                        { return nil }
+
+type stubConn struct{ connector *stubConnector }
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) { return &stubStmt{c}, nil }
+func (c *stubConn) Close() error                              { return nil }
+func (c *stubConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type stubStmt struct{ conn *stubConn }
+
+func (s *stubStmt) Close() error                               { return nil }
+func (s *stubStmt) NumInput() int                              { return -1 }
+func (s *stubStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (s *stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &stubRows{columns: s.conn.connector.columns, values: s.conn.connector.values}, nil
+}
+
+type stubRows struct {
+	columns []string
+	values  [][]driver.Value
+	index   int
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+func (r *stubRows) Close() error      { return nil }
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.index])
+	r.index++
+	return nil
+}
+
+func TestGroup(t *testing.T) {
+	type Project struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	type Tag struct {
+		Name string `db:"name"`
+	}
+	type User struct {
+		ID       int64      `db:"id"`
+		Name     string     `db:"name"`
+		Projects []*Project `db:"project"`
+		Tags     []Tag      `db:"tag"`
+	}
+	db := NewDB(sql.OpenDB(&stubConnector{
+		columns: []string{"id", "name", "project.id", "project.name", "tag.name"},
+		values: [][]driver.Value{
+			{int64(1), "a", int64(10), "p10", "x"},
+			{int64(1), "a", int64(10), "p10", "y"},
+			{int64(1), "a", int64(11), "p11", "x"},
+			{int64(1), "a", int64(11), "p11", "y"},
+			{int64(2), "b", nil, nil, nil},
+			{int64(3), "c", int64(12), "p12", nil},
+		},
+	}), "stub")
+	defer db.Close()
+
+	var users []*User
+	if err := db.Select(Group(&users, "id"), "SELECT"); err != nil {
+		t.Fatalf("group: %s", err)
+	}
+	expect := []*User{
+		{ID: 1, Name: "a", Projects: []*Project{{10, "p10"}, {11, "p11"}}, Tags: []Tag{{"x"}, {"y"}}},
+		{ID: 2, Name: "b"},
+		{ID: 3, Name: "c", Projects: []*Project{{12, "p12"}}},
+	}
+	if !reflect.DeepEqual(users, expect) {
+		t.Fatalf("group: %+v != %+v", users, expect)
+	}
+
+	var values []User
+	if err := db.Select(Group(&values, "id", "name"), "SELECT"); err != nil {
+		t.Fatalf("group: %s", err)
+	}
+	if len(values) != 3 || len(values[0].Projects) != 2 || len(values[0].Tags) != 2 || values[1].Projects != nil {
+		t.Fatalf("group: %+v", values)
+	}
+
+	if err := db.Select(Group(&users, "missing"), "SELECT"); err == nil ||
+		!strings.Contains(err.Error(), "key column missing not found") {
+		t.Fatalf("group: expects missing key error, got %v", err)
+	}
+	var projects []Project
+	if err := db.Select(Group(&projects, "id"), "SELECT"); err == nil ||
+		!strings.Contains(err.Error(), "missing destination name project.id") {
+		t.Fatalf("group: expects missing destination error, got %v", err)
+	}
+}